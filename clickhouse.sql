@@ -31,6 +31,14 @@ CREATE TABLE IF NOT EXISTS tracer.trace_spans (
     EventTimesUs     Array(Int64),
     EventAttrs       Array(String), -- 存储 JSON 字符串数组
 
+    -- 关联信息 (ChildOf / FollowFrom / Link，可跨 trace 跳转)
+    Links            Nested(
+        TraceID      String,
+        SpanID       String,
+        RefType      LowCardinality(String),
+        Attributes   String -- JSON
+    ),
+
 -- 其他信息
     ProcessID        String
 
@@ -40,4 +48,12 @@ CREATE TABLE IF NOT EXISTS tracer.trace_spans (
 -- 关键配置 2: 排序键，决定了数据在磁盘上的存放顺序，也是查询索引
     ORDER BY (ServiceName, OperationName, TimestampUs)
 -- 关键配置 3: 设置数据保存时间 (例如保存 7 天)
-    TTL toDateTime(TimestampUs / 1000000) + INTERVAL 7 DAY
+    TTL toDateTime(TimestampUs / 1000000) + INTERVAL 7 DAY;
+
+-- 3. 已有的表升级：增加 Links 列
+ALTER TABLE tracer.trace_spans ADD COLUMN IF NOT EXISTS Links Nested(
+    TraceID      String,
+    SpanID       String,
+    RefType      LowCardinality(String),
+    Attributes   String
+) AFTER EventAttrs;
@@ -1,43 +1,93 @@
 package cmd
 
 import (
-	"fmt"
 	"log"
-	"time"
+	"os"
 	"tracer/internal/agent"
+	"tracer/pkg/metrics"
 	"tracer/pkg/model"
 )
 
 // NewAgent initializes and starts the agent components.
 // It sets up the buffer, aggregator, and exporter to process trace data.
-// The agent listens on port 8888 for incoming spans.
-func NewAgent() {
-	bufferToAggregator := make(chan model.Package, 512)
-	aggregatorToExporter := make(chan model.BatchPackage, 200)
+// The configuration is read from args, the AGENT_* environment variables and the file given by -config;
+// with -print-config it only prints the effective configuration.
+func NewAgent(args []string) error {
+	conf, err := agent.LoadConfig(args)
+	if err != nil {
+		return err
+	}
+
+	if conf.PrintConfig {
+		return conf.Print(os.Stdout)
+	}
+
+	// The admin server exposes the agent metrics in the Prometheus format
+	var prometheus *metrics.PrometheusFactory
+	if conf.Admin.Addr != "" {
+		prometheus = metrics.NewPrometheusFactory()
+		conf.Metrics = prometheus
+	}
+
+	bufferToAggregator := make(chan model.Package, conf.Aggregator.QueueSize)
+	aggregatorToExporter := make(chan model.BatchPackage, conf.Exporter.QueueSize)
 
 	// Create a new Buffer to receive spans
-	buffer, err := agent.NewBuffer(":8888", bufferToAggregator)
+	buffer, err := agent.NewBuffer(conf, bufferToAggregator)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
 
 	// Create a new Aggregator to batch spans
-	aggregator := agent.NewAggregator(time.Second*2, bufferToAggregator, aggregatorToExporter, 1000)
+	aggregator := agent.NewAggregator(conf.Aggregator.Interval, bufferToAggregator, aggregatorToExporter, conf.Aggregator.MaxSpans)
 
 	// Create a new Exporter to send batches to the collector
-	exporter, err := agent.NewExporter(10, aggregatorToExporter)
+	exporter, err := agent.NewExporter(conf, aggregatorToExporter)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
 	}
 
+	// Batches the exporter cannot take yet go to its on-disk queue, if enabled
+	aggregator.SetOverflow(exporter.Spill)
+
 	aggregator.Start()
 	exporter.Start()
 
-	// Start listening for incoming data
-	if err := buffer.Listen(); err != nil {
-		fmt.Println(err)
-		return
+	// The HTTP intake feeds the same aggregator channel as UDP
+	if conf.HTTP.Addr != "" {
+		receiver := agent.NewHTTPReceiver(conf, buffer)
+		go func() {
+			if err := receiver.ListenAndServe(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+
+	// OTLP/HTTP is served by the HTTP intake, OTLP/gRPC has its own listener
+	if conf.OTLP.Enabled && conf.OTLP.GRPCAddr != "" {
+		receiver := agent.NewOTLPReceiver(conf, buffer)
+		go func() {
+			if err := receiver.ListenAndServe(); err != nil {
+				log.Println(err)
+			}
+		}()
 	}
+
+	if conf.Admin.Addr != "" {
+		admin := agent.NewAdminServer(conf, prometheus, exporter.Ready)
+		agentMetrics := metrics.NewAgentMetrics(prometheus)
+		admin.OnScrape(func() {
+			agentMetrics.QueueDepth("buffer_to_aggregator").Update(int64(len(bufferToAggregator)))
+			agentMetrics.QueueDepth("aggregator_to_exporter").Update(int64(len(aggregatorToExporter)))
+			aggregator.ReportMetrics(agentMetrics)
+		})
+		go func() {
+			if err := admin.ListenAndServe(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+
+	// Start listening for incoming data
+	return buffer.Listen()
 }
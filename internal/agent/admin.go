@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Admin endpoints.
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+	MetricsPath = "/metrics"
+)
+
+// AdminServer serves the health, readiness and metrics endpoints of the agent.
+// /healthz answers 200 while the process is up, /readyz answers 503 until ready returns true,
+// and /metrics runs the OnScrape callbacks before writing the metrics.
+type AdminServer struct {
+	server  *http.Server
+	mux     *http.ServeMux
+	ready   func() bool
+	metrics http.Handler
+
+	mu       sync.Mutex
+	onScrape []func()
+}
+
+// NewAdminServer creates the admin server listening on conf.Admin.Addr.
+// metrics renders the Prometheus text format, usually the metrics.PrometheusFactory in conf.Metrics.
+func NewAdminServer(conf *Config, metrics http.Handler, ready func() bool) *AdminServer {
+	s := &AdminServer{
+		mux:     http.NewServeMux(),
+		ready:   ready,
+		metrics: metrics,
+	}
+	s.server = &http.Server{
+		Addr:              conf.Admin.Addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.mux.HandleFunc(HealthzPath, s.handleHealthz)
+	s.mux.HandleFunc(ReadyzPath, s.handleReadyz)
+	s.mux.HandleFunc(MetricsPath, s.handleMetrics)
+
+	return s
+}
+
+// OnScrape registers f to run before every /metrics response, to sample gauges such as queue depths.
+func (s *AdminServer) OnScrape(f func()) {
+	s.mu.Lock()
+	s.onScrape = append(s.onScrape, f)
+	s.mu.Unlock()
+}
+
+// ServeHTTP makes the server usable in tests and behind another server.
+func (s *AdminServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(w, req)
+}
+
+// ListenAndServe serves until Close is called, then returns nil.
+func (s *AdminServer) ListenAndServe() error {
+	log.Println("agent admin server listening on", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Serve is ListenAndServe on an existing listener.
+func (s *AdminServer) Serve(lis net.Listener) error {
+	if err := s.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Close stops the listener.
+func (s *AdminServer) Close() error {
+	return s.server.Close()
+}
+
+func (s *AdminServer) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	_, _ = io.WriteString(w, "ok\n")
+}
+
+// handleReadyz answers 503 while no collector can take batches, so Kubernetes stops routing spans here.
+func (s *AdminServer) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	if s.ready != nil && !s.ready() {
+		http.Error(w, "no collector available", http.StatusServiceUnavailable)
+		return
+	}
+
+	_, _ = io.WriteString(w, "ok\n")
+}
+
+func (s *AdminServer) handleMetrics(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	callbacks := append([]func(){}, s.onScrape...)
+	s.mu.Unlock()
+
+	for _, f := range callbacks {
+		f()
+	}
+
+	s.metrics.ServeHTTP(w, req)
+}
@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tracer/pkg/metrics"
+	"tracer/pkg/model"
+	"tracer/pkg/span"
+)
+
+// get serves one GET request on handler and returns the recorded response.
+func get(handler http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestAdminServerHealthz(t *testing.T) {
+	admin := NewAdminServer(DefaultConfig(), metrics.NewPrometheusFactory(), func() bool { return false })
+
+	if rec := get(admin, HealthzPath); rec.Code != http.StatusOK || rec.Body.String() != "ok\n" {
+		t.Fatalf("%s = %d %q, want 200 ok even when not ready", HealthzPath, rec.Code, rec.Body.String())
+	}
+}
+
+func TestAdminServerReadyz(t *testing.T) {
+	b := newTestBalancer(t, startCollector(t, &blockingCollector{}))
+	admin := NewAdminServer(DefaultConfig(), metrics.NewPrometheusFactory(), b.Ready)
+
+	if rec := get(admin, ReadyzPath); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("%s = %d before the first health check, want 503", ReadyzPath, rec.Code)
+	}
+
+	// 没注册健康检查服务的 collector 也算健康
+	b.checkHealth(context.Background())
+
+	if rec := get(admin, ReadyzPath); rec.Code != http.StatusOK {
+		t.Fatalf("%s = %d after the collector answered, want 200", ReadyzPath, rec.Code)
+	}
+}
+
+func TestAdminServerReadyzUnreachable(t *testing.T) {
+	// 没人监听的端口，健康检查会失败
+	b := newTestBalancer(t, "127.0.0.1:1")
+	b.conf.HealthCheckTimeout = 100 * time.Millisecond
+	admin := NewAdminServer(DefaultConfig(), metrics.NewPrometheusFactory(), b.Ready)
+
+	b.checkHealth(context.Background())
+
+	if rec := get(admin, ReadyzPath); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("%s = %d with no reachable collector, want 503", ReadyzPath, rec.Code)
+	}
+}
+
+func TestAdminServerMetrics(t *testing.T) {
+	prometheus := metrics.NewPrometheusFactory()
+	admin := NewAdminServer(DefaultConfig(), prometheus, nil)
+	agentMetrics := metrics.NewAgentMetrics(prometheus)
+	agentMetrics.PacketsReceived.Inc(3)
+
+	depth := int64(0)
+	admin.OnScrape(func() {
+		depth++
+		agentMetrics.QueueDepth("buffer_to_aggregator").Update(depth)
+	})
+
+	rec := get(admin, MetricsPath)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s = %d, want 200", MetricsPath, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	for _, want := range []string{
+		"# TYPE agent_packets_received_total counter\n",
+		"agent_packets_received_total 3\n",
+		"# TYPE agent_queue_depth gauge\n",
+		`agent_queue_depth{queue="buffer_to_aggregator"} 1` + "\n",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics have no %q:\n%s", want, rec.Body.String())
+		}
+	}
+
+	// OnScrape 每次抓取都会跑
+	if body := get(admin, MetricsPath).Body.String(); !strings.Contains(body, `agent_queue_depth{queue="buffer_to_aggregator"} 2`) {
+		t.Fatalf("the gauge was not sampled again on the second scrape:\n%s", body)
+	}
+}
+
+func TestAggregatorReportMetricsCapsServices(t *testing.T) {
+	const services = maxReportedServices + 5
+
+	a := NewAggregator(time.Hour, nil, make(chan model.BatchPackage, services), 1000)
+	for i := 0; i < services; i++ {
+		a.Append(model.Package{
+			Process: model.Process{ServiceName: fmt.Sprintf("svc-%03d", i)},
+			Spans:   []span.ToModel{{Operation: "op"}},
+		})
+	}
+
+	prometheus := metrics.NewPrometheusFactory()
+	agentMetrics := metrics.NewAgentMetrics(prometheus)
+	a.ReportMetrics(agentMetrics)
+
+	series := bufferedSpans(t, prometheus)
+	if len(series) != maxReportedServices+1 {
+		t.Fatalf("%d service labels, want %d and %q", len(series), maxReportedServices, otherServices)
+	}
+	if series[otherServices] != "5" {
+		t.Fatalf("%s = %s, want the 5 spans of the services over the cap", otherServices, series[otherServices])
+	}
+
+	// 发送之后已有的标签报 0，不会消失
+	a.sendAll()
+	a.ReportMetrics(agentMetrics)
+	for service, value := range bufferedSpans(t, prometheus) {
+		if value != "0" {
+			t.Fatalf("%s = %s after the buffers were sent, want 0", service, value)
+		}
+	}
+}
+
+// bufferedSpans returns the agent_aggregator_buffered_spans series by service label.
+func bufferedSpans(t *testing.T, prometheus *metrics.PrometheusFactory) map[string]string {
+	t.Helper()
+	var buf strings.Builder
+	if _, err := prometheus.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	series := make(map[string]string)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		rest, ok := strings.CutPrefix(line, `agent_aggregator_buffered_spans{service="`)
+		if !ok {
+			continue
+		}
+		service, value, _ := strings.Cut(rest, `"} `)
+		series[service] = value
+	}
+	return series
+}
@@ -1,23 +1,39 @@
 package agent
 
 import (
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+	"tracer/pkg/metrics"
 	"tracer/pkg/model"
 	"tracer/pkg/span"
 )
 
-// Aggregator batches spans by service name.
-// It sends batches when either the time duration or max queue size is reached.
+// maxReportedServices bounds the service label of the buffered spans gauge.
+const maxReportedServices = 100
+
+// otherServices collects the buffered spans of services beyond maxReportedServices.
+const otherServices = "other"
+
+// Aggregator batches spans by process: service name and process tags, so the resource tags
+// of every instance of a service are kept. It sends batches when either the time duration
+// or max queue size is reached.
 type Aggregator struct {
-	mu           sync.Mutex
-	batchCh      <-chan model.Package
-	maxQueue     uint
+	mu       sync.Mutex
+	batchCh  <-chan model.Package
+	maxQueue uint
+	// batchPackage is keyed by processKey.
 	batchPackage map[string]*model.Package
 	timer        *time.Timer
 	outputCh     chan<- model.BatchPackage
 	duration     time.Duration
+	overflow     func(bp model.BatchPackage)
+	// reported are the services that have a label on the buffered spans gauge.
+	reportMu sync.Mutex
+	reported map[string]struct{}
 }
 
 // NewAggregator creates a new Aggregator.
@@ -38,6 +54,57 @@ func NewAggregator(
 	return a
 }
 
+// SetOverflow sets where batches go when the output channel is full, instead of being dropped.
+// It must be called before Start.
+func (a *Aggregator) SetOverflow(overflow func(bp model.BatchPackage)) {
+	a.overflow = overflow
+}
+
+// BufferSizes returns the number of spans waiting in the buffers of each service.
+func (a *Aggregator) BufferSizes() map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sizes := make(map[string]int, len(a.batchPackage))
+	for _, pkg := range a.batchPackage {
+		sizes[pkg.Process.ServiceName] += len(pkg.Spans)
+	}
+
+	return sizes
+}
+
+// ReportMetrics publishes the buffer sizes. Service names come from clients, so only the first
+// maxReportedServices get their own label; the spans of the others are summed under "other".
+// A labelled service with nothing buffered is reported as 0.
+func (a *Aggregator) ReportMetrics(m *metrics.AgentMetrics) {
+	sizes := a.BufferSizes()
+
+	a.reportMu.Lock()
+	defer a.reportMu.Unlock()
+
+	if a.reported == nil {
+		a.reported = make(map[string]struct{})
+	}
+
+	totals := make(map[string]int64, len(a.reported))
+	for service := range a.reported {
+		totals[service] = 0
+	}
+	for service, n := range sizes {
+		if _, ok := a.reported[service]; !ok {
+			if len(a.reported) >= maxReportedServices {
+				service = otherServices
+			}
+			a.reported[service] = struct{}{}
+		}
+		totals[service] += int64(n)
+	}
+
+	for service, n := range totals {
+		m.AggregatorBufferedSpans(service).Update(n)
+	}
+}
+
 // Start runs the aggregator loop in a goroutine.
 func (a *Aggregator) Start() {
 	go a.Run()
@@ -67,20 +134,20 @@ func (a *Aggregator) Run() {
 	}
 }
 
-// Append adds a package to the aggregation buffer.
-// Returns true if the buffer for the service is full and was sent.
+// Append adds a package to the aggregation buffer of its process.
+// Returns true if the buffer for the process is full and was sent.
 func (a *Aggregator) Append(pkg model.Package) bool {
-	service := pkg.Process.ServiceName
+	key := processKey(&pkg.Process)
 
 	a.mu.Lock()
 
-	// Initialize buffer for service if not exists
-	bp, ok := a.batchPackage[service]
+	// Initialize buffer for process if not exists
+	bp, ok := a.batchPackage[key]
 	if !ok {
 		bp = &model.Package{
 			Process: pkg.Process,
 		}
-		a.batchPackage[service] = bp
+		a.batchPackage[key] = bp
 	}
 
 	bp.Spans = append(bp.Spans, pkg.Spans...)
@@ -89,18 +156,18 @@ func (a *Aggregator) Append(pkg model.Package) bool {
 	a.mu.Unlock()
 
 	if isFull {
-		a.Send(service)
-		a.Flush(service)
+		a.Send(key)
+		a.Flush(key)
 		return true
 	}
 
 	return false
 }
 
-// Send sends the batched spans for a specific service to the output channel.
-func (a *Aggregator) Send(serviceName string) {
+// Send sends the batched spans of the process with the given processKey to the output channel.
+func (a *Aggregator) Send(key string) {
 	a.mu.Lock()
-	pkg, ok := a.batchPackage[serviceName]
+	pkg, ok := a.batchPackage[key]
 	if !ok || len(pkg.Spans) == 0 {
 		a.mu.Unlock()
 		return
@@ -112,25 +179,33 @@ func (a *Aggregator) Send(serviceName string) {
 	}
 	a.mu.Unlock()
 
-	bp := model.BatchPackage{
+	a.output(model.BatchPackage{
 		Packages: []model.Package{
 			out,
 		},
-	}
+	})
+}
 
+// output hands the batch to the exporter without blocking; when the channel is full
+// the batch goes to the overflow, or is dropped if there is none.
+func (a *Aggregator) output(bp model.BatchPackage) {
 	select {
 	case a.outputCh <- bp:
 		// Sent successfully
 	default:
+		if a.overflow != nil {
+			a.overflow(bp)
+			return
+		}
 		// Queue full, drop data to prevent blocking
 		log.Println("Aggregator output channel full, dropping batch")
 	}
 }
 
-// Flush clears the buffer for a specific service.
-func (a *Aggregator) Flush(serviceName string) {
+// Flush clears the buffer of the process with the given processKey.
+func (a *Aggregator) Flush(key string) {
 	a.mu.Lock()
-	delete(a.batchPackage, serviceName)
+	delete(a.batchPackage, key)
 	a.mu.Unlock()
 }
 
@@ -154,5 +229,17 @@ func (a *Aggregator) sendAll() {
 		bp.Packages = append(bp.Packages, *pkg)
 	}
 
-	a.outputCh <- bp
+	a.output(bp)
+}
+
+// processKey identifies a process by its service name, ID and tags, so two instances of a
+// service (different host.name, container.id, process.pid, ...) get their own buffer.
+func processKey(p *model.Process) string {
+	tags := make([]string, 0, len(p.Tags))
+	for _, tag := range p.Tags {
+		tags = append(tags, tag.Key+"="+fmt.Sprint(tag.Value))
+	}
+	sort.Strings(tags)
+
+	return p.ServiceName + "|" + p.ID + "|" + strings.Join(tags, ",")
 }
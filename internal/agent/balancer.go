@@ -0,0 +1,425 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"hash/fnv"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	pb "tracer/internal/proto"
+	"tracer/pkg/metrics"
+)
+
+// Balancer policies.
+const (
+	PolicyRoundRobin  = "round_robin"
+	PolicyLeastLoaded = "least_loaded"
+	// PolicyTraceID sends all spans of a trace to the same collector, so tail sampling there sees whole traces.
+	PolicyTraceID = "trace_id"
+)
+
+// backend is one collector address with its own connection.
+type backend struct {
+	addr   string
+	conn   *grpc.ClientConn
+	client pb.CollectorServiceClient
+	health healthpb.HealthClient
+	// breaker has its own lock, so one failing collector does not stop the calls to the others.
+	breaker *CircuitBreaker
+
+	// inflight counts the calls between Pick and Done. It is only increased with Balancer.mu held,
+	// so a backend removed by refresh gets no new calls and is closed when it drops to zero.
+	inflight atomic.Int64
+
+	// 以下字段由 Balancer.mu 保护
+	// removed is set once DNS no longer returns the address; the conn is closed after the last call.
+	removed      bool
+	failures     int
+	ejectedUntil time.Time
+	unhealthy    bool
+	// checked is set once a health check has answered; until then the collector does not count as ready.
+	checked bool
+}
+
+// Balancer spreads batches across the collectors in conf.Collector.Endpoints.
+// Each endpoint is resolved through DNS, so one name can stand for many collectors.
+// A collector is ejected for EjectDuration after MaxFailures consecutive failures,
+// and skipped while its gRPC health check does not report SERVING or its circuit breaker is open.
+// If every collector is out, all of them are used again rather than none.
+type Balancer struct {
+	mu       sync.RWMutex
+	backends []*backend // sorted by addr
+
+	endpoints   []string
+	creds       credentials.TransportCredentials
+	conf        BalancerConfig
+	breakerConf BreakerConfig
+	metrics     *metrics.AgentMetrics
+	next        atomic.Uint64
+}
+
+// routedBatch is the part of a batch that goes to one collector.
+// key is a trace ID under PolicyTraceID and empty otherwise.
+type routedBatch struct {
+	key string
+	req *pb.BatchPackage
+}
+
+// NewBalancer resolves the endpoints and connects to every collector found.
+func NewBalancer(conf *Config, creds credentials.TransportCredentials) (*Balancer, error) {
+	b := new(Balancer)
+	if err := b.init(conf, creds); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// init resolves the endpoints once; an endpoint that does not resolve yet is retried by Start.
+func (b *Balancer) init(conf *Config, creds credentials.TransportCredentials) error {
+	b.endpoints = conf.Collector.Endpoints
+	b.creds = creds
+	b.conf = conf.Collector.Balancer
+	b.breakerConf = conf.Collector.Breaker
+	b.metrics = metrics.NewAgentMetrics(conf.Metrics)
+
+	b.refresh(context.Background())
+	if len(b.backends) == 0 {
+		return fmt.Errorf("balancer: no collector address could be resolved from %v", b.endpoints)
+	}
+
+	return nil
+}
+
+// Start runs the DNS refresh and the health checks until ctx is done.
+// The first health check runs before Start returns, so Ready reflects a real answer.
+// Without health checks the connections are dialed right away instead of on the first export.
+func (b *Balancer) Start(ctx context.Context) {
+	if b.conf.DNSRefreshInterval > 0 {
+		go b.every(ctx, b.conf.DNSRefreshInterval, b.refresh)
+	}
+	if b.conf.HealthCheckInterval > 0 {
+		b.checkHealth(ctx)
+		go b.every(ctx, b.conf.HealthCheckInterval, b.checkHealth)
+		return
+	}
+
+	b.mu.RLock()
+	for _, be := range b.backends {
+		be.conn.Connect()
+	}
+	b.mu.RUnlock()
+}
+
+func (b *Balancer) every(ctx context.Context, interval time.Duration, f func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f(ctx)
+		}
+	}
+}
+
+// Split cuts the batch into the parts that go to different collectors.
+// Only PolicyTraceID splits; the other policies send the batch as a whole.
+func (b *Balancer) Split(req *pb.BatchPackage) []routedBatch {
+	if b.conf.Policy != PolicyTraceID {
+		return []routedBatch{{req: req}}
+	}
+
+	b.mu.RLock()
+	candidates := b.available()
+	b.mu.RUnlock()
+
+	parts := make(map[*backend]*pb.BatchPackage)
+	keys := make(map[*backend]string)
+	var order []*backend
+	for _, pkg := range req.Packages {
+		byBackend := make(map[*backend]*pb.Package)
+		for _, s := range pkg.Spans {
+			traceID := s.GetContext().GetTraceId()
+			be := pickByKey(candidates, traceID)
+
+			p, ok := byBackend[be]
+			if !ok {
+				p = &pb.Package{Process: pkg.Process}
+				byBackend[be] = p
+				if _, seen := parts[be]; !seen {
+					parts[be] = &pb.BatchPackage{}
+					keys[be] = traceID
+					order = append(order, be)
+				}
+				parts[be].Packages = append(parts[be].Packages, p)
+			}
+			p.Spans = append(p.Spans, s)
+		}
+	}
+
+	routed := make([]routedBatch, 0, len(order))
+	for _, be := range order {
+		routed = append(routed, routedBatch{key: keys[be], req: parts[be]})
+	}
+
+	return routed
+}
+
+// Pick chooses the collector for one call; key is the routing key returned by Split.
+// It returns nil only when no collector address is known. Every backend returned must be
+// handed back to Done, which keeps its connection open until the call is over.
+func (b *Balancer) Pick(key string) *backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	be := b.pick(b.available(), key)
+	if be != nil {
+		be.inflight.Add(1)
+	}
+
+	return be
+}
+
+// pick applies the policy to the candidates. b.mu must be held.
+func (b *Balancer) pick(candidates []*backend, key string) *backend {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch b.conf.Policy {
+	case PolicyTraceID:
+		return pickByKey(candidates, key)
+	case PolicyLeastLoaded:
+		// 从轮询位置开始找，负载相同的时候不会总落到第一个
+		start := int(b.next.Add(1))
+		best := candidates[start%len(candidates)]
+		for i := 1; i < len(candidates); i++ {
+			be := candidates[(start+i)%len(candidates)]
+			if be.inflight.Load() < best.inflight.Load() {
+				best = be
+			}
+		}
+		return best
+	default:
+		return candidates[int(b.next.Add(1)-1)%len(candidates)]
+	}
+}
+
+// Done records the result of a call made to be, and closes its connection if refresh removed it
+// while the call was running.
+func (b *Balancer) Done(be *backend, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.release(be) {
+		return
+	}
+
+	if err == nil || !isCollectorFailure(err) {
+		be.failures = 0
+		return
+	}
+
+	be.failures++
+	if be.failures >= b.conf.MaxFailures {
+		be.failures = 0
+		be.ejectedUntil = time.Now().Add(b.conf.EjectDuration)
+		log.Printf("balancer: ejecting collector %s for %v: %v", be.addr, b.conf.EjectDuration, err)
+	}
+}
+
+// Release hands back a backend that was picked but not called, without recording a result.
+func (b *Balancer) Release(be *backend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.release(be)
+}
+
+// release ends a call on be and closes it if it was removed and this was the last call.
+// It reports whether be was closed. b.mu must be held.
+func (b *Balancer) release(be *backend) bool {
+	if be.inflight.Add(-1) == 0 && be.removed {
+		closeBackend(be)
+		return true
+	}
+
+	return false
+}
+
+// Ready reports whether at least one collector is usable: not ejected, its breaker not open,
+// and either answered its last health check with SERVING or, when health checks are off, has a connected channel.
+func (b *Balancer) Ready() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	now := time.Now()
+	for _, be := range b.backends {
+		if be.unhealthy || !now.After(be.ejectedUntil) || !be.breaker.Ready() {
+			continue
+		}
+		if b.conf.HealthCheckInterval > 0 {
+			if be.checked {
+				return true
+			}
+			continue
+		}
+
+		switch be.conn.GetState() {
+		case connectivity.Ready:
+			return true
+		case connectivity.Idle:
+			// 空闲超时后连接会回到 Idle，重新连上，下次再判断
+			be.conn.Connect()
+		}
+	}
+
+	return false
+}
+
+// available returns the collectors that can take calls, or all of them if none can. b.mu must be held.
+func (b *Balancer) available() []*backend {
+	now := time.Now()
+	out := make([]*backend, 0, len(b.backends))
+	for _, be := range b.backends {
+		if !be.unhealthy && now.After(be.ejectedUntil) && be.breaker.Ready() {
+			out = append(out, be)
+		}
+	}
+	if len(out) == 0 {
+		return b.backends
+	}
+
+	return out
+}
+
+// pickByKey uses rendezvous hashing: removing a collector only moves the traces that were on it.
+func pickByKey(candidates []*backend, key string) *backend {
+	var best *backend
+	var bestScore uint64
+	for _, be := range candidates {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte(be.addr))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = be, score
+		}
+	}
+
+	return best
+}
+
+// refresh resolves the endpoints again, connecting to new addresses and closing the ones that went away.
+// A collector that went away stops getting calls at once; its connection is closed by Done
+// when the calls already running on it are over, so they are not cancelled.
+func (b *Balancer) refresh(ctx context.Context) {
+	// address -> endpoint it came from, used as the TLS authority
+	resolved := make(map[string]string)
+	for _, endpoint := range b.endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			log.Println("balancer:", err)
+			continue
+		}
+
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			log.Printf("balancer: resolve %s: %v", host, err)
+			continue
+		}
+		for _, addr := range addrs {
+			resolved[net.JoinHostPort(addr, port)] = endpoint
+		}
+	}
+	if len(resolved) == 0 {
+		// DNS 暂时不可用时保留现有的 collector
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := make([]*backend, 0, len(resolved))
+	for _, be := range b.backends {
+		if _, ok := resolved[be.addr]; ok {
+			kept = append(kept, be)
+			delete(resolved, be.addr)
+			continue
+		}
+		be.removed = true
+		if be.inflight.Load() == 0 {
+			closeBackend(be)
+		}
+	}
+
+	for addr, endpoint := range resolved {
+		conn, err := grpc.NewClient(addr,
+			grpc.WithTransportCredentials(b.creds),
+			grpc.WithAuthority(endpoint))
+		if err != nil {
+			log.Printf("balancer: connect %s: %v", addr, err)
+			continue
+		}
+		kept = append(kept, &backend{
+			addr:    addr,
+			conn:    conn,
+			client:  pb.NewCollectorServiceClient(conn),
+			health:  healthpb.NewHealthClient(conn),
+			breaker: NewCircuitBreaker(b.breakerConf, b.metrics.BreakerState(addr)),
+		})
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].addr < kept[j].addr })
+	b.backends = kept
+}
+
+// closeBackend closes the connection of a removed collector.
+func closeBackend(be *backend) {
+	if err := be.conn.Close(); err != nil {
+		log.Println("balancer:", err)
+	}
+}
+
+// checkHealth asks every collector for its gRPC health status.
+// A collector without the health service is treated as healthy.
+func (b *Balancer) checkHealth(ctx context.Context) {
+	b.mu.RLock()
+	backends := append([]*backend(nil), b.backends...)
+	b.mu.RUnlock()
+
+	for _, be := range backends {
+		checkCtx, cancel := context.WithTimeout(ctx, b.conf.HealthCheckTimeout)
+		resp, err := be.health.Check(checkCtx, &healthpb.HealthCheckRequest{})
+		cancel()
+
+		unhealthy := false
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+		case err != nil:
+			unhealthy = true
+		default:
+			unhealthy = resp.GetStatus() != healthpb.HealthCheckResponse_SERVING
+		}
+
+		b.mu.Lock()
+		if unhealthy != be.unhealthy {
+			log.Printf("balancer: collector %s unhealthy=%v", be.addr, unhealthy)
+		}
+		be.unhealthy = unhealthy
+		be.checked = true
+		b.mu.Unlock()
+	}
+}
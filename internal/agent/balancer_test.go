@@ -0,0 +1,350 @@
+package agent
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+	pb "tracer/internal/proto"
+)
+
+// blockingCollector answers Export once release is closed, and reports each call on started.
+type blockingCollector struct {
+	pb.UnimplementedCollectorServiceServer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCollector) Export(ctx context.Context, _ *pb.BatchPackage) (*pb.ExportResponse, error) {
+	c.started <- struct{}{}
+	select {
+	case <-c.release:
+		return &pb.ExportResponse{Success: true}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startCollector serves c on a loopback port and returns its address.
+func startCollector(t *testing.T, c pb.CollectorServiceServer) string {
+	t.Helper()
+	return startServer(t, func(server *grpc.Server) {
+		pb.RegisterCollectorServiceServer(server, c)
+	})
+}
+
+// startHealthCollector serves a collector whose gRPC health service reports st.
+func startHealthCollector(t *testing.T, st healthpb.HealthCheckResponse_ServingStatus) string {
+	t.Helper()
+	return startServer(t, func(server *grpc.Server) {
+		pb.RegisterCollectorServiceServer(server, &blockingCollector{})
+		hs := health.NewServer()
+		hs.SetServingStatus("", st)
+		healthpb.RegisterHealthServer(server, hs)
+	})
+}
+
+func startServer(t *testing.T, register func(server *grpc.Server)) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	register(server)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	return lis.Addr().String()
+}
+
+func newTestBalancer(t *testing.T, endpoints ...string) *Balancer {
+	t.Helper()
+	conf := DefaultConfig()
+	conf.Collector.Endpoints = endpoints
+	b, err := NewBalancer(conf, insecure.NewCredentials())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBalancerRefreshKeepsRunningCalls(t *testing.T) {
+	old := &blockingCollector{started: make(chan struct{}, 1), release: make(chan struct{})}
+	oldAddr := startCollector(t, old)
+	newAddr := startCollector(t, &blockingCollector{started: make(chan struct{}, 1), release: make(chan struct{})})
+
+	b := newTestBalancer(t, oldAddr)
+	be := b.Pick("")
+	if be == nil || be.addr != oldAddr {
+		t.Fatalf("picked %v, want %s", be, oldAddr)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := be.client.Export(ctx, &pb.BatchPackage{})
+		errCh <- err
+	}()
+	<-old.started
+
+	// DNS now returns only the new collector
+	b.endpoints = []string{newAddr}
+	b.refresh(context.Background())
+
+	if picked := b.Pick(""); picked == nil || picked.addr != newAddr {
+		t.Fatalf("picked %v after the refresh, want %s", picked, newAddr)
+	} else {
+		b.Done(picked, nil)
+	}
+	if state := be.conn.GetState(); state == connectivity.Shutdown {
+		t.Fatal("the removed collector's connection was closed while a call was running")
+	}
+
+	close(old.release)
+	err := <-errCh
+	if err != nil {
+		t.Fatalf("Export on the removed collector = %v, want it to finish", err)
+	}
+	b.Done(be, err)
+
+	if state := be.conn.GetState(); state != connectivity.Shutdown {
+		t.Fatalf("connection state = %v after the last call, want Shutdown", state)
+	}
+}
+
+func TestBalancerRefreshClosesIdleBackends(t *testing.T) {
+	oldAddr := startCollector(t, &blockingCollector{})
+	newAddr := startCollector(t, &blockingCollector{})
+
+	b := newTestBalancer(t, oldAddr)
+	be := b.Pick("")
+	b.Done(be, nil)
+
+	b.endpoints = []string{newAddr}
+	b.refresh(context.Background())
+
+	if state := be.conn.GetState(); state != connectivity.Shutdown {
+		t.Fatalf("connection state = %v, want an idle removed collector closed at once", state)
+	}
+}
+
+// newPolicyBalancer returns a balancer with the given policy over n collectors that are never dialed.
+func newPolicyBalancer(t *testing.T, policy string, n int) *Balancer {
+	t.Helper()
+	endpoints := make([]string, n)
+	for i := range endpoints {
+		endpoints[i] = "127.0.0.1:" + strconv.Itoa(i+1)
+	}
+	b := newTestBalancer(t, endpoints...)
+	b.conf.Policy = policy
+	return b
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	b := newPolicyBalancer(t, PolicyRoundRobin, 3)
+
+	picks := make(map[string]int)
+	for i := 0; i < 9; i++ {
+		be := b.Pick("")
+		picks[be.addr]++
+		b.Done(be, nil)
+	}
+
+	if len(picks) != 3 {
+		t.Fatalf("picks = %v, want all 3 collectors", picks)
+	}
+	for addr, n := range picks {
+		if n != 3 {
+			t.Fatalf("picks = %v, want 3 calls on %s", picks, addr)
+		}
+	}
+}
+
+func TestBalancerLeastLoaded(t *testing.T) {
+	b := newPolicyBalancer(t, PolicyLeastLoaded, 3)
+
+	var busy []*backend
+	for i := 0; i < 3; i++ {
+		be := b.Pick("")
+		for _, other := range busy {
+			if be == other {
+				t.Fatalf("picked %s twice while another collector had no calls", be.addr)
+			}
+		}
+		busy = append(busy, be)
+	}
+
+	// 只有 idle 上没有进行中的调用
+	idle := busy[1]
+	b.Done(idle, nil)
+	for i := 0; i < 3; i++ {
+		be := b.Pick("")
+		b.Done(be, nil)
+		if be != idle {
+			t.Fatalf("picked %s, want the idle collector %s", be.addr, idle.addr)
+		}
+	}
+}
+
+func TestBalancerTraceIDSurvivesRemoval(t *testing.T) {
+	b := newPolicyBalancer(t, PolicyTraceID, 3)
+
+	const traces = 200
+	before := make(map[string]string, traces)
+	for i := 0; i < traces; i++ {
+		key := strconv.Itoa(i)
+		be := b.Pick(key)
+		before[key] = be.addr
+		b.Done(be, nil)
+	}
+
+	removed := b.backends[0].addr
+	b.endpoints = []string{b.backends[1].addr, b.backends[2].addr}
+	b.refresh(context.Background())
+
+	moved := 0
+	for key, addr := range before {
+		be := b.Pick(key)
+		b.Done(be, nil)
+		if addr == removed {
+			moved++
+			continue
+		}
+		if be.addr != addr {
+			t.Fatalf("trace %s moved from %s to %s, though %s was removed", key, addr, be.addr, removed)
+		}
+	}
+	if moved == 0 {
+		t.Fatalf("no trace was on %s, the test does not cover a removal", removed)
+	}
+}
+
+func TestBalancerEjection(t *testing.T) {
+	b := newPolicyBalancer(t, PolicyRoundRobin, 2)
+	b.conf.MaxFailures = 2
+	b.conf.EjectDuration = 50 * time.Millisecond
+	failing := b.backends[0]
+
+	unavailable := status.Error(codes.Unavailable, "collector down")
+	for i := 0; i < b.conf.MaxFailures; i++ {
+		failing.inflight.Add(1)
+		b.Done(failing, unavailable)
+	}
+
+	for i := 0; i < 4; i++ {
+		be := b.Pick("")
+		b.Done(be, nil)
+		if be == failing {
+			t.Fatalf("picked %s within EjectDuration after %d failures", be.addr, b.conf.MaxFailures)
+		}
+	}
+
+	time.Sleep(b.conf.EjectDuration)
+
+	picked := false
+	for i := 0; i < 4; i++ {
+		be := b.Pick("")
+		b.Done(be, nil)
+		picked = picked || be == failing
+	}
+	if !picked {
+		t.Fatalf("%s was not picked again after EjectDuration", failing.addr)
+	}
+}
+
+func TestBalancerEjectionNotRetryable(t *testing.T) {
+	b := newPolicyBalancer(t, PolicyRoundRobin, 2)
+	b.conf.MaxFailures = 1
+	be := b.backends[0]
+
+	// 数据本身有问题，不是 collector 的错
+	be.inflight.Add(1)
+	b.Done(be, status.Error(codes.InvalidArgument, "bad batch"))
+
+	if !be.ejectedUntil.IsZero() {
+		t.Fatalf("%s was ejected for a non-retryable error", be.addr)
+	}
+}
+
+func TestBalancerCheckHealth(t *testing.T) {
+	notServing := startHealthCollector(t, healthpb.HealthCheckResponse_NOT_SERVING)
+	serving := startHealthCollector(t, healthpb.HealthCheckResponse_SERVING)
+	unimplemented := startCollector(t, &blockingCollector{})
+
+	b := newTestBalancer(t, notServing, serving, unimplemented)
+	b.checkHealth(context.Background())
+
+	if !b.Ready() {
+		t.Fatal("not ready with two healthy collectors")
+	}
+
+	picks := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		be := b.Pick("")
+		picks[be.addr]++
+		b.Done(be, nil)
+	}
+	if picks[notServing] != 0 {
+		t.Fatalf("picks = %v, want none on the NOT_SERVING collector %s", picks, notServing)
+	}
+	if picks[serving] == 0 || picks[unimplemented] == 0 {
+		t.Fatalf("picks = %v, want calls on %s and on %s without a health service", picks, serving, unimplemented)
+	}
+}
+
+func TestBalancerSplit(t *testing.T) {
+	spans := func(traceIDs ...string) []*pb.SpanModel {
+		out := make([]*pb.SpanModel, 0, len(traceIDs))
+		for _, id := range traceIDs {
+			out = append(out, &pb.SpanModel{Context: &pb.SpanContext{TraceId: id}})
+		}
+		return out
+	}
+	req := &pb.BatchPackage{Packages: []*pb.Package{
+		{Process: &pb.Process{ServiceName: "shop"}, Spans: spans("t1", "t2", "t3", "t1", "t4")},
+		{Process: &pb.Process{ServiceName: "cart"}, Spans: spans("t2", "t5", "t6", "t3")},
+	}}
+
+	if routed := newPolicyBalancer(t, PolicyRoundRobin, 3).Split(req); len(routed) != 1 || routed[0].req != req {
+		t.Fatalf("round_robin split the batch into %d parts, want it whole", len(routed))
+	}
+
+	b := newPolicyBalancer(t, PolicyTraceID, 3)
+	routed := b.Split(req)
+
+	total := 0
+	traceTo := make(map[string]*backend)
+	for _, part := range routed {
+		be := b.Pick(part.key)
+		b.Done(be, nil)
+		for _, pkg := range part.req.Packages {
+			if pkg.Process.ServiceName != "shop" && pkg.Process.ServiceName != "cart" {
+				t.Fatalf("package lost its process: %v", pkg.Process)
+			}
+			for _, s := range pkg.Spans {
+				total++
+				traceID := s.Context.TraceId
+				if other, ok := traceTo[traceID]; ok && other != be {
+					t.Fatalf("trace %s was split across %s and %s", traceID, other.addr, be.addr)
+				}
+				traceTo[traceID] = be
+				if want := pickByKey(b.backends, traceID); want != be {
+					t.Fatalf("trace %s routed to %s, want %s", traceID, be.addr, want.addr)
+				}
+			}
+		}
+	}
+	if total != 9 {
+		t.Fatalf("%d spans after the split, want 9", total)
+	}
+}
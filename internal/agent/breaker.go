@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"sync"
+	"time"
+	"tracer/pkg/metrics"
+)
+
+// CircuitBreaker stops export calls to a collector that keeps failing. Every collector has its own.
+// After FailureThreshold consecutive failures it opens and rejects calls; after OpenTimeout
+// it lets a single probe through (half-open), which closes it on success or opens it again on failure.
+// Every call Allow lets through must be followed by Success or Failure, or a half-open breaker
+// never closes. Its state is published on the BreakerState gauge. A nil *CircuitBreaker always allows calls.
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	state       int64
+	failures    int
+	threshold   int
+	openTimeout time.Duration
+	openedAt    time.Time
+	gauge       metrics.Gauge
+}
+
+// NewCircuitBreaker returns nil when the breaker is disabled.
+func NewCircuitBreaker(conf BreakerConfig, gauge metrics.Gauge) *CircuitBreaker {
+	if !conf.Enabled {
+		return nil
+	}
+
+	b := &CircuitBreaker{
+		state:       metrics.BreakerClosed,
+		threshold:   conf.FailureThreshold,
+		openTimeout: conf.OpenTimeout,
+		gauge:       gauge,
+	}
+	b.gauge.Update(metrics.BreakerClosed)
+
+	return b
+}
+
+// Allow reports whether a call may be made now.
+func (b *CircuitBreaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case metrics.BreakerClosed:
+		return true
+	case metrics.BreakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		// 打开时间到了，放一个探测请求过去
+		b.setState(metrics.BreakerHalfOpen)
+		return true
+	default:
+		// 半开状态下已经有探测请求在路上
+		return false
+	}
+}
+
+// Ready reports whether Allow would let a call through, without starting a probe.
+func (b *CircuitBreaker) Ready() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case metrics.BreakerClosed:
+		return true
+	case metrics.BreakerOpen:
+		return time.Since(b.openedAt) >= b.openTimeout
+	default:
+		return false
+	}
+}
+
+// Success records that the collector answered.
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.setState(metrics.BreakerClosed)
+}
+
+// Failure records that the collector could not be reached or failed.
+func (b *CircuitBreaker) Failure() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == metrics.BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(metrics.BreakerOpen)
+	}
+}
+
+// State returns BreakerClosed, BreakerHalfOpen or BreakerOpen.
+func (b *CircuitBreaker) State() int64 {
+	if b == nil {
+		return metrics.BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *CircuitBreaker) setState(state int64) {
+	b.state = state
+	b.gauge.Update(state)
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"testing"
+	"time"
+	"tracer/pkg/metrics"
+)
+
+func newTestBreaker(threshold int) *CircuitBreaker {
+	return NewCircuitBreaker(BreakerConfig{Enabled: true, FailureThreshold: threshold, OpenTimeout: time.Hour},
+		metrics.NullFactory.Gauge("breaker", nil))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const (
+		success = "success"
+		failure = "failure"
+		allow   = "allow"
+		deny    = "deny"
+		// elapse moves the opening back past OpenTimeout
+		elapse = "elapse"
+	)
+
+	tests := []struct {
+		name  string
+		steps []string
+		want  int64
+	}{
+		{"starts closed", []string{allow}, metrics.BreakerClosed},
+		{"failures below the threshold", []string{failure, failure, allow}, metrics.BreakerClosed},
+		{"success resets the failures", []string{failure, failure, success, failure, failure, allow}, metrics.BreakerClosed},
+		{"opens at the threshold", []string{failure, failure, failure, deny}, metrics.BreakerOpen},
+		{"stays open until the timeout", []string{failure, failure, failure, deny, deny}, metrics.BreakerOpen},
+		{"one probe after the timeout", []string{failure, failure, failure, elapse, allow, deny}, metrics.BreakerHalfOpen},
+		{"probe success closes", []string{failure, failure, failure, elapse, allow, success, allow}, metrics.BreakerClosed},
+		{"probe failure opens again", []string{failure, failure, failure, elapse, allow, failure, deny}, metrics.BreakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker(3)
+			for i, step := range tt.steps {
+				switch step {
+				case success:
+					b.Success()
+				case failure:
+					b.Failure()
+				case elapse:
+					b.openedAt = b.openedAt.Add(-2 * b.openTimeout)
+				case allow, deny:
+					ready := b.Ready()
+					if got := b.Allow(); got != (step == allow) || ready != got {
+						t.Fatalf("step %d: Allow = %v, Ready = %v, want %v", i, got, ready, step == allow)
+					}
+				}
+			}
+			if got := b.State(); got != tt.want {
+				t.Fatalf("state = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{}, metrics.NullFactory.Gauge("breaker", nil))
+	for i := 0; i < 10; i++ {
+		b.Failure()
+	}
+	if !b.Allow() || !b.Ready() || b.State() != metrics.BreakerClosed {
+		t.Fatal("a disabled breaker rejected a call")
+	}
+}
@@ -2,23 +2,74 @@ package agent
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"net"
+	"os"
+	"sync"
+	"sync/atomic"
 	"tracer/pkg/config"
+	"tracer/pkg/metrics"
 	"tracer/pkg/model"
+	"tracer/pkg/resource"
 )
 
+// maxTrackedSenders bounds the per-sender error map, so a flood of spoofed addresses cannot grow it forever.
+const maxTrackedSenders = 1024
+
+// otherSenders collects the errors of senders beyond maxTrackedSenders.
+const otherSenders = "other"
+
 // Buffer receives incoming spans via UDP.
+// One goroutine reads datagrams and hands them to a pool of decode workers,
+// so a malformed or slow packet never stops the intake.
 type Buffer struct {
-	conn    *net.UDPConn
-	addr    *net.UDPAddr
-	batchCh chan<- model.Package
+	conn     *net.UDPConn
+	addr     *net.UDPAddr
+	batchCh  chan<- model.Package
+	hostname string
+	tags     []config.Tag
+
+	workers       int
+	maxPacketSize int
+	packetCh      chan packet
+	bufPool       sync.Pool
+
+	received     atomic.Uint64
+	queueDropped atomic.Uint64
+	dropped      atomic.Uint64
+	decodeErrors atomic.Uint64
+	metrics      *metrics.AgentMetrics
+
+	mu             sync.Mutex
+	errorsBySender map[string]uint64
+}
+
+// packet is a datagram waiting for a decode worker. buf goes back to the pool once decoded.
+type packet struct {
+	buf    *[]byte
+	n      int
+	sender string
+}
+
+// BufferStats is a snapshot of the intake counters.
+type BufferStats struct {
+	// Received counts datagrams read from the socket and HTTP requests read by the HTTP intake.
+	Received uint64
+	// QueueDropped counts datagrams dropped because every decode worker was busy.
+	QueueDropped uint64
+	// Dropped counts decoded packages dropped because the aggregator was saturated.
+	Dropped uint64
+	// DecodeErrors counts datagrams and HTTP bodies that were not a valid package.
+	DecodeErrors uint64
+	// ErrorsBySender counts decode errors per sender IP.
+	ErrorsBySender map[string]uint64
 }
 
-// NewBuffer creates a new Buffer listening on the specified IP address.
-func NewBuffer(ipAddr string, batchCh chan<- model.Package) (*Buffer, error) {
+// NewBuffer creates a new Buffer listening on conf.UDP.Addr.
+func NewBuffer(conf *Config, batchCh chan<- model.Package) (*Buffer, error) {
 	b := new(Buffer)
-	if err := b.init(ipAddr, batchCh); err != nil {
+	if err := b.init(conf, batchCh); err != nil {
 		return nil, err
 	}
 
@@ -26,8 +77,8 @@ func NewBuffer(ipAddr string, batchCh chan<- model.Package) (*Buffer, error) {
 }
 
 // init initializes the UDP connection.
-func (b *Buffer) init(ipAddr string, batchCh chan<- model.Package) error {
-	addr, err := net.ResolveUDPAddr("udp", ipAddr)
+func (b *Buffer) init(conf *Config, batchCh chan<- model.Package) error {
+	addr, err := net.ResolveUDPAddr("udp", conf.UDP.Addr)
 	if err != nil {
 		return err
 	}
@@ -37,48 +88,172 @@ func (b *Buffer) init(ipAddr string, batchCh chan<- model.Package) error {
 		return err
 	}
 
+	if conf.UDP.ReadBufferSize > 0 {
+		// 内核可能把它截到 net.core.rmem_max，失败也不影响接收
+		if err := conn.SetReadBuffer(conf.UDP.ReadBufferSize); err != nil {
+			log.Println("buffer: set read buffer:", err)
+		}
+	}
+
 	b.conn = conn
 	b.addr = addr
 	b.batchCh = batchCh
+	b.tags = conf.enrichTags()
+	b.hostname, err = os.Hostname()
+	if err != nil {
+		b.hostname = resource.PrimaryIP()
+	}
+
+	b.workers = conf.UDP.Workers
+	b.maxPacketSize = conf.UDP.MaxPacketSize
+	b.packetCh = make(chan packet, conf.UDP.QueueSize)
+	b.bufPool.New = func() interface{} {
+		buf := make([]byte, b.maxPacketSize)
+		return &buf
+	}
+	b.errorsBySender = make(map[string]uint64)
+	b.metrics = metrics.NewAgentMetrics(conf.Metrics)
 
 	return nil
 }
 
-// close closes the UDP connection.
-func (b *Buffer) close() {
-	err := b.conn.Close()
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
+// Close closes the UDP connection, which makes Listen return.
+func (b *Buffer) Close() error {
+	return b.conn.Close()
 }
 
 // Listen starts listening for incoming UDP packets and processes them.
+// It returns nil once the Buffer is closed, and an error only if the socket fails.
 func (b *Buffer) Listen() error {
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.decodeLoop()
+		}()
+	}
+	defer func() {
+		close(b.packetCh)
+		wg.Wait()
+	}()
+
 	for {
-		buf := make([]byte, 65535)
-		n, _, err := b.conn.ReadFromUDP(buf)
+		buf := b.bufPool.Get().(*[]byte)
+		n, sender, err := b.conn.ReadFromUDP(*buf)
 		if err != nil {
+			b.bufPool.Put(buf)
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
 			return err
 		}
 
+		b.recordReceived()
+		p := packet{buf: buf, n: n}
+		if sender != nil {
+			p.sender = sender.IP.String()
+		}
+
+		// worker 都忙时直接丢弃，不能阻塞读 socket，否则内核缓冲区满了丢得更多
+		select {
+		case b.packetCh <- p:
+		default:
+			b.bufPool.Put(buf)
+			b.queueDropped.Add(1)
+			b.metrics.PacketsDroppedBusy.Inc(1)
+		}
+	}
+}
+
+// decodeLoop decodes datagrams until packetCh is closed.
+func (b *Buffer) decodeLoop() {
+	for p := range b.packetCh {
 		var batch model.Package
-		if err := json.Unmarshal(buf[:n], &batch); err != nil {
-			return err
+		err := json.Unmarshal((*p.buf)[:p.n], &batch)
+		b.bufPool.Put(p.buf)
+		if err != nil {
+			b.recordDecodeError(p.sender)
+			continue
 		}
 
-		batch = b.Enrich(batch)
+		b.Offer(b.Enrich(batch))
+	}
+}
+
+// Offer hands a package to the aggregator without blocking.
+// It returns false and counts a drop when the aggregator is saturated.
+func (b *Buffer) Offer(batch model.Package) bool {
+	select {
+	case b.batchCh <- batch:
+		return true
+	default:
+		b.dropped.Add(1)
+		b.metrics.PacketsDroppedFull.Inc(1)
+		return false
+	}
+}
+
+// OfferAll enriches and offers every package, for intakes that receive several services at once.
+// It returns how many packages were queued and how many spans were dropped.
+func (b *Buffer) OfferAll(batches []model.Package) (queued int, droppedSpans int64) {
+	for _, batch := range batches {
+		if b.Offer(b.Enrich(batch)) {
+			queued++
+		} else {
+			droppedSpans += int64(len(batch.Spans))
+		}
+	}
+
+	return queued, droppedSpans
+}
+
+func (b *Buffer) recordReceived() {
+	b.received.Add(1)
+	b.metrics.PacketsReceived.Inc(1)
+}
+
+func (b *Buffer) recordDecodeError(sender string) {
+	b.decodeErrors.Add(1)
+	b.metrics.DecodeErrors.Inc(1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.errorsBySender[sender]; !ok && len(b.errorsBySender) >= maxTrackedSenders {
+		sender = otherSenders
+	}
+	b.errorsBySender[sender]++
+}
+
+// Stats returns a snapshot of the intake counters.
+func (b *Buffer) Stats() BufferStats {
+	b.mu.Lock()
+	bySender := make(map[string]uint64, len(b.errorsBySender))
+	for sender, n := range b.errorsBySender {
+		bySender[sender] = n
+	}
+	b.mu.Unlock()
 
-		b.batchCh <- batch
+	return BufferStats{
+		Received:       b.received.Load(),
+		QueueDropped:   b.queueDropped.Load(),
+		Dropped:        b.dropped.Load(),
+		DecodeErrors:   b.decodeErrors.Load(),
+		ErrorsBySender: bySender,
 	}
 }
 
-// Enrich adds additional metadata (like agent tags) to the package.
+// Enrich adds additional metadata (the agent host and the configured static tags) to the package.
 func (b *Buffer) Enrich(batch model.Package) model.Package {
 	batch.Process.Tags = append(batch.Process.Tags, config.Tag{
 		Key:   "agent.host",
-		Value: "node-1",
+		Value: b.hostname,
 	})
+	batch.Process.Tags = append(batch.Process.Tags, b.tags...)
 
 	return batch
 }
@@ -0,0 +1,632 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"tracer/pkg/config"
+	"tracer/pkg/metrics"
+)
+
+// EnvPrefix is prepended to every flag name to get its environment variable,
+// e.g. -collector.endpoints is also read from AGENT_COLLECTOR_ENDPOINTS.
+const EnvPrefix = "AGENT_"
+
+// Config holds everything the agent needs to run.
+// Precedence is flags > env > file > defaults.
+type Config struct {
+	UDP        UDPConfig        `yaml:"udp"`
+	HTTP       HTTPConfig       `yaml:"http"`
+	OTLP       OTLPConfig       `yaml:"otlp"`
+	Zipkin     ZipkinConfig     `yaml:"zipkin"`
+	Admin      AdminConfig      `yaml:"admin"`
+	Collector  CollectorConfig  `yaml:"collector"`
+	Aggregator AggregatorConfig `yaml:"aggregator"`
+	Exporter   ExporterConfig   `yaml:"exporter"`
+	WAL        WALConfig        `yaml:"wal"`
+	// Tags are added to the process tags of every package the agent receives.
+	Tags map[string]string `yaml:"tags"`
+	// Metrics receives the agent self-telemetry; nil discards it.
+	Metrics metrics.Factory `yaml:"-"`
+
+	// ConfigPath and PrintConfig only come from flags or env.
+	ConfigPath  string `yaml:"-"`
+	PrintConfig bool   `yaml:"-"`
+}
+
+type UDPConfig struct {
+	Addr string `yaml:"addr"`
+	// Workers decode datagrams in parallel with the socket reader.
+	Workers int `yaml:"workers"`
+	// QueueSize is the number of datagrams waiting for a worker; more are dropped.
+	QueueSize int `yaml:"queue_size"`
+	// ReadBufferSize is the socket receive buffer (SO_RCVBUF) in bytes; 0 keeps the OS default.
+	ReadBufferSize int `yaml:"read_buffer_size"`
+	// MaxPacketSize is the largest datagram accepted; longer ones are truncated and fail to decode.
+	MaxPacketSize int `yaml:"max_packet_size"`
+}
+
+// HTTPConfig is the HTTP intake, for clients that cannot send UDP.
+type HTTPConfig struct {
+	// Addr is the listen address; empty disables the HTTP intake.
+	Addr string `yaml:"addr"`
+	// MaxBodySize caps a request body in bytes, before and after gzip decompression.
+	MaxBodySize int64 `yaml:"max_body_size"`
+}
+
+// OTLPConfig is the OpenTelemetry intake: TraceService/Export over gRPC,
+// and /v1/traces on the HTTP intake.
+type OTLPConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// GRPCAddr is the OTLP/gRPC listen address; empty leaves only OTLP/HTTP.
+	GRPCAddr string `yaml:"grpc_addr"`
+}
+
+// ZipkinConfig is the Zipkin v2 JSON intake at /api/v2/spans on the HTTP intake.
+type ZipkinConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// AdminConfig is the listener of /healthz, /readyz and /metrics.
+type AdminConfig struct {
+	// Addr is the listen address; empty disables the admin server.
+	Addr string `yaml:"addr"`
+}
+
+type CollectorConfig struct {
+	// Endpoints are collector gRPC addresses (host:port). A host name resolving to several IPs
+	// counts as one collector per IP.
+	Endpoints []string       `yaml:"endpoints"`
+	Balancer  BalancerConfig `yaml:"balancer"`
+	TLS       TLSConfig      `yaml:"tls"`
+	Timeout   time.Duration  `yaml:"timeout"`
+	Retry     RetryConfig    `yaml:"retry"`
+	Breaker   BreakerConfig  `yaml:"breaker"`
+}
+
+// BalancerConfig chooses how batches are spread over the collectors and when a collector is taken out.
+type BalancerConfig struct {
+	// Policy is round_robin, least_loaded or trace_id.
+	Policy string `yaml:"policy"`
+	// DNSRefreshInterval is how often the endpoints are resolved again; 0 resolves them only at start.
+	DNSRefreshInterval time.Duration `yaml:"dns_refresh_interval"`
+	// HealthCheckInterval is how often the gRPC health service is queried; 0 disables health checks.
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+	HealthCheckTimeout  time.Duration `yaml:"health_check_timeout"`
+	// MaxFailures consecutive failed exports eject a collector for EjectDuration.
+	MaxFailures   int           `yaml:"max_failures"`
+	EjectDuration time.Duration `yaml:"eject_duration"`
+}
+
+// RetryConfig retries exports that failed with Unavailable, ResourceExhausted or DeadlineExceeded.
+// The delay starts at InitialInterval and grows by Multiplier up to MaxInterval, spread by ±Jitter;
+// a delay sent by the collector in a RetryInfo detail is used instead when present.
+type RetryConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	InitialInterval time.Duration `yaml:"initial_interval"`
+	MaxInterval     time.Duration `yaml:"max_interval"`
+	Multiplier      float64       `yaml:"multiplier"`
+	// Jitter is a fraction of the delay, e.g. 0.2 for ±20%.
+	Jitter float64 `yaml:"jitter"`
+	// MaxElapsedTime bounds the time spent retrying one batch.
+	MaxElapsedTime time.Duration `yaml:"max_elapsed_time"`
+}
+
+// BreakerConfig opens the circuit after FailureThreshold consecutive failures
+// and probes the collector again after OpenTimeout.
+type BreakerConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	FailureThreshold int           `yaml:"failure_threshold"`
+	OpenTimeout      time.Duration `yaml:"open_timeout"`
+}
+
+// TLSConfig secures the connection to the collector. With Enabled false the connection is plaintext.
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile verifies the collector certificate; empty uses the system pool.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are the client certificate for mutual TLS. Set both or neither.
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type AggregatorConfig struct {
+	// Interval flushes all buffered spans even if no service reached MaxSpans.
+	Interval time.Duration `yaml:"interval"`
+	// MaxSpans flushes a service as soon as it has buffered that many spans.
+	MaxSpans uint `yaml:"max_spans"`
+	// QueueSize is the capacity of the channel from the intake to the aggregator.
+	QueueSize int `yaml:"queue_size"`
+}
+
+type ExporterConfig struct {
+	Workers int `yaml:"workers"`
+	// QueueSize is the capacity of the channel from the aggregator to the exporter.
+	QueueSize int `yaml:"queue_size"`
+}
+
+// WALConfig enables the on-disk queue that keeps batches while the collector is unreachable.
+type WALConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+	// MaxSize caps the bytes on disk; batches that do not fit are dropped.
+	MaxSize int64 `yaml:"max_size"`
+	// SegmentSize is the size at which a new segment file is started.
+	SegmentSize int64 `yaml:"segment_size"`
+	// ReplayInterval is how often the queue is retried against the collector.
+	ReplayInterval time.Duration `yaml:"replay_interval"`
+}
+
+// DefaultConfig returns the configuration used when nothing else is set.
+func DefaultConfig() *Config {
+	return &Config{
+		UDP: UDPConfig{
+			Addr:           ":8888",
+			Workers:        4,
+			QueueSize:      1000,
+			ReadBufferSize: 4 << 20,
+			MaxPacketSize:  65535,
+		},
+		HTTP: HTTPConfig{
+			Addr:        ":8889",
+			MaxBodySize: 5 << 20,
+		},
+		OTLP: OTLPConfig{
+			Enabled:  true,
+			GRPCAddr: ":4317",
+		},
+		Zipkin: ZipkinConfig{
+			Enabled: true,
+		},
+		Admin: AdminConfig{
+			Addr: ":8890",
+		},
+		Collector: CollectorConfig{
+			Endpoints: []string{"localhost:50051"},
+			Balancer: BalancerConfig{
+				Policy:              PolicyRoundRobin,
+				DNSRefreshInterval:  30 * time.Second,
+				HealthCheckInterval: 10 * time.Second,
+				HealthCheckTimeout:  time.Second,
+				MaxFailures:         3,
+				EjectDuration:       30 * time.Second,
+			},
+			Timeout: 5 * time.Second,
+			Retry: RetryConfig{
+				Enabled:         true,
+				InitialInterval: 500 * time.Millisecond,
+				MaxInterval:     10 * time.Second,
+				Multiplier:      2,
+				Jitter:          0.2,
+				MaxElapsedTime:  30 * time.Second,
+			},
+			Breaker: BreakerConfig{
+				Enabled:          true,
+				FailureThreshold: 5,
+				OpenTimeout:      10 * time.Second,
+			},
+		},
+		Aggregator: AggregatorConfig{
+			Interval:  2 * time.Second,
+			MaxSpans:  1000,
+			QueueSize: 512,
+		},
+		Exporter: ExporterConfig{
+			Workers:   10,
+			QueueSize: 200,
+		},
+		WAL: WALConfig{
+			Dir:            "./data/wal",
+			MaxSize:        1 << 30,
+			SegmentSize:    16 << 20,
+			ReplayInterval: 5 * time.Second,
+		},
+	}
+}
+
+// LoadConfig builds the configuration from the defaults, the YAML file given by -config
+// (or AGENT_CONFIG), the AGENT_* environment variables and the command line args, in that order.
+func LoadConfig(args []string) (*Config, error) {
+	// 第一遍只为了拿到 -config，其余 flag 在文件和环境变量之后再解析
+	pre := DefaultConfig()
+	fs := pre.flagSet(io.Discard)
+	_ = fs.Parse(args)
+	path := pre.ConfigPath
+	if path == "" {
+		path = os.Getenv(envName("config"))
+	}
+
+	c := DefaultConfig()
+	if path != "" {
+		if err := c.applyFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	fs = c.flagSet(os.Stderr)
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := fs.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("agent config: %s: %w", envName(f.Name), setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	c.ConfigPath = path
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// flagSet binds a flag to every setting; the current values are the flag defaults.
+func (c *Config) flagSet(output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	fs.StringVar(&c.ConfigPath, "config", c.ConfigPath, "path to a YAML config file")
+	fs.BoolVar(&c.PrintConfig, "print-config", c.PrintConfig, "print the effective configuration as YAML and exit")
+
+	fs.StringVar(&c.UDP.Addr, "udp.addr", c.UDP.Addr, "UDP address to receive spans on")
+	fs.IntVar(&c.UDP.Workers, "udp.workers", c.UDP.Workers, "number of datagram decode workers")
+	fs.IntVar(&c.UDP.QueueSize, "udp.queue-size", c.UDP.QueueSize, "datagrams waiting for a decode worker before new ones are dropped")
+	fs.IntVar(&c.UDP.ReadBufferSize, "udp.read-buffer-size", c.UDP.ReadBufferSize, "socket receive buffer (SO_RCVBUF) in bytes, 0 keeps the OS default")
+	fs.IntVar(&c.UDP.MaxPacketSize, "udp.max-packet-size", c.UDP.MaxPacketSize, "largest datagram accepted in bytes")
+
+	fs.StringVar(&c.HTTP.Addr, "http.addr", c.HTTP.Addr, "HTTP intake address, empty to disable")
+	fs.Int64Var(&c.HTTP.MaxBodySize, "http.max-body-size", c.HTTP.MaxBodySize, "largest HTTP request body in bytes")
+
+	fs.BoolVar(&c.OTLP.Enabled, "otlp.enabled", c.OTLP.Enabled, "accept OpenTelemetry traces over OTLP")
+	fs.StringVar(&c.OTLP.GRPCAddr, "otlp.grpc-addr", c.OTLP.GRPCAddr, "OTLP/gRPC address, empty to accept OTLP/HTTP only")
+
+	fs.BoolVar(&c.Zipkin.Enabled, "zipkin.enabled", c.Zipkin.Enabled, "accept Zipkin v2 JSON spans on the HTTP intake")
+
+	fs.StringVar(&c.Admin.Addr, "admin.addr", c.Admin.Addr, "address of /healthz, /readyz and /metrics, empty to disable")
+
+	fs.Var((*listValue)(&c.Collector.Endpoints), "collector.endpoints", "comma separated collector gRPC addresses")
+	fs.StringVar(&c.Collector.Balancer.Policy, "collector.balancer.policy", c.Collector.Balancer.Policy, "round_robin, least_loaded or trace_id")
+	fs.DurationVar(&c.Collector.Balancer.DNSRefreshInterval, "collector.balancer.dns-refresh-interval", c.Collector.Balancer.DNSRefreshInterval, "how often collector endpoints are resolved again, 0 to resolve once")
+	fs.DurationVar(&c.Collector.Balancer.HealthCheckInterval, "collector.balancer.health-check-interval", c.Collector.Balancer.HealthCheckInterval, "how often collectors are health checked, 0 to disable")
+	fs.DurationVar(&c.Collector.Balancer.HealthCheckTimeout, "collector.balancer.health-check-timeout", c.Collector.Balancer.HealthCheckTimeout, "timeout of one health check")
+	fs.IntVar(&c.Collector.Balancer.MaxFailures, "collector.balancer.max-failures", c.Collector.Balancer.MaxFailures, "consecutive failures that eject a collector")
+	fs.DurationVar(&c.Collector.Balancer.EjectDuration, "collector.balancer.eject-duration", c.Collector.Balancer.EjectDuration, "how long an ejected collector is skipped")
+	fs.DurationVar(&c.Collector.Timeout, "collector.timeout", c.Collector.Timeout, "timeout of one export call")
+	fs.BoolVar(&c.Collector.Retry.Enabled, "collector.retry.enabled", c.Collector.Retry.Enabled, "retry exports that failed with a retryable code")
+	fs.DurationVar(&c.Collector.Retry.InitialInterval, "collector.retry.initial-interval", c.Collector.Retry.InitialInterval, "first retry delay")
+	fs.DurationVar(&c.Collector.Retry.MaxInterval, "collector.retry.max-interval", c.Collector.Retry.MaxInterval, "largest retry delay")
+	fs.Float64Var(&c.Collector.Retry.Multiplier, "collector.retry.multiplier", c.Collector.Retry.Multiplier, "growth of the retry delay")
+	fs.Float64Var(&c.Collector.Retry.Jitter, "collector.retry.jitter", c.Collector.Retry.Jitter, "random spread of the retry delay, as a fraction")
+	fs.DurationVar(&c.Collector.Retry.MaxElapsedTime, "collector.retry.max-elapsed-time", c.Collector.Retry.MaxElapsedTime, "time spent retrying one batch")
+	fs.BoolVar(&c.Collector.Breaker.Enabled, "collector.breaker.enabled", c.Collector.Breaker.Enabled, "stop calling a failing collector for a while")
+	fs.IntVar(&c.Collector.Breaker.FailureThreshold, "collector.breaker.failure-threshold", c.Collector.Breaker.FailureThreshold, "consecutive failures that open the circuit")
+	fs.DurationVar(&c.Collector.Breaker.OpenTimeout, "collector.breaker.open-timeout", c.Collector.Breaker.OpenTimeout, "time before an open circuit probes the collector again")
+	fs.BoolVar(&c.Collector.TLS.Enabled, "collector.tls.enabled", c.Collector.TLS.Enabled, "use TLS to connect to the collector")
+	fs.StringVar(&c.Collector.TLS.CAFile, "collector.tls.ca-file", c.Collector.TLS.CAFile, "CA certificate to verify the collector")
+	fs.StringVar(&c.Collector.TLS.CertFile, "collector.tls.cert-file", c.Collector.TLS.CertFile, "client certificate for mutual TLS")
+	fs.StringVar(&c.Collector.TLS.KeyFile, "collector.tls.key-file", c.Collector.TLS.KeyFile, "client key for mutual TLS")
+	fs.StringVar(&c.Collector.TLS.ServerName, "collector.tls.server-name", c.Collector.TLS.ServerName, "override the server name checked in the collector certificate")
+	fs.BoolVar(&c.Collector.TLS.InsecureSkipVerify, "collector.tls.insecure-skip-verify", c.Collector.TLS.InsecureSkipVerify, "do not verify the collector certificate")
+
+	fs.DurationVar(&c.Aggregator.Interval, "aggregator.interval", c.Aggregator.Interval, "flush interval of the aggregator")
+	fs.UintVar(&c.Aggregator.MaxSpans, "aggregator.max-spans", c.Aggregator.MaxSpans, "spans per service that trigger a flush")
+	fs.IntVar(&c.Aggregator.QueueSize, "aggregator.queue-size", c.Aggregator.QueueSize, "capacity of the intake to aggregator channel")
+
+	fs.IntVar(&c.Exporter.Workers, "exporter.workers", c.Exporter.Workers, "number of export workers")
+	fs.IntVar(&c.Exporter.QueueSize, "exporter.queue-size", c.Exporter.QueueSize, "capacity of the aggregator to exporter channel")
+
+	fs.BoolVar(&c.WAL.Enabled, "wal.enabled", c.WAL.Enabled, "keep failed and overflowing batches in an on-disk queue")
+	fs.StringVar(&c.WAL.Dir, "wal.dir", c.WAL.Dir, "directory of the on-disk queue")
+	fs.Int64Var(&c.WAL.MaxSize, "wal.max-size", c.WAL.MaxSize, "max bytes of the on-disk queue")
+	fs.Int64Var(&c.WAL.SegmentSize, "wal.segment-size", c.WAL.SegmentSize, "bytes per segment file")
+	fs.DurationVar(&c.WAL.ReplayInterval, "wal.replay-interval", c.WAL.ReplayInterval, "how often the on-disk queue is replayed")
+
+	fs.Var((*tagsValue)(&c.Tags), "tags", "comma separated key=value tags added to every process")
+
+	return fs
+}
+
+// Validate checks that the configuration can be used to run the agent.
+func (c *Config) Validate() error {
+	if c.UDP.Addr == "" {
+		return fmt.Errorf("agent config: udp.addr is required")
+	}
+	if c.UDP.Workers <= 0 {
+		return fmt.Errorf("agent config: udp.workers must be greater than 0, got %d", c.UDP.Workers)
+	}
+	if c.UDP.QueueSize < 0 {
+		return fmt.Errorf("agent config: udp.queue_size must not be negative, got %d", c.UDP.QueueSize)
+	}
+	if c.UDP.ReadBufferSize < 0 {
+		return fmt.Errorf("agent config: udp.read_buffer_size must not be negative, got %d", c.UDP.ReadBufferSize)
+	}
+	if c.UDP.MaxPacketSize <= 0 || c.UDP.MaxPacketSize > 65535 {
+		return fmt.Errorf("agent config: udp.max_packet_size must be between 1 and 65535, got %d", c.UDP.MaxPacketSize)
+	}
+
+	if c.HTTP.Addr != "" && c.HTTP.MaxBodySize <= 0 {
+		return fmt.Errorf("agent config: http.max_body_size must be greater than 0, got %d", c.HTTP.MaxBodySize)
+	}
+
+	if err := c.validateListenAddrs(); err != nil {
+		return err
+	}
+
+	if len(c.Collector.Endpoints) == 0 {
+		return fmt.Errorf("agent config: at least one collector endpoint is required")
+	}
+	for _, endpoint := range c.Collector.Endpoints {
+		if !strings.Contains(endpoint, ":") {
+			return fmt.Errorf("agent config: collector endpoint %q must be host:port", endpoint)
+		}
+	}
+	balancer := c.Collector.Balancer
+	switch balancer.Policy {
+	case PolicyRoundRobin, PolicyLeastLoaded, PolicyTraceID:
+	default:
+		return fmt.Errorf("agent config: collector.balancer.policy %q is not supported, want %s, %s or %s",
+			balancer.Policy, PolicyRoundRobin, PolicyLeastLoaded, PolicyTraceID)
+	}
+	if balancer.DNSRefreshInterval < 0 || balancer.HealthCheckInterval < 0 {
+		return fmt.Errorf("agent config: collector.balancer intervals must not be negative")
+	}
+	if balancer.HealthCheckInterval > 0 && balancer.HealthCheckTimeout <= 0 {
+		return fmt.Errorf("agent config: collector.balancer.health_check_timeout must be greater than 0, got %v", balancer.HealthCheckTimeout)
+	}
+	if balancer.MaxFailures <= 0 {
+		return fmt.Errorf("agent config: collector.balancer.max_failures must be greater than 0, got %d", balancer.MaxFailures)
+	}
+	if balancer.EjectDuration <= 0 {
+		return fmt.Errorf("agent config: collector.balancer.eject_duration must be greater than 0, got %v", balancer.EjectDuration)
+	}
+	if c.Collector.Timeout <= 0 {
+		return fmt.Errorf("agent config: collector.timeout must be greater than 0, got %v", c.Collector.Timeout)
+	}
+
+	retry := c.Collector.Retry
+	if retry.Enabled {
+		if retry.InitialInterval <= 0 || retry.MaxInterval < retry.InitialInterval {
+			return fmt.Errorf("agent config: collector.retry intervals must satisfy 0 < initial_interval <= max_interval, got %v and %v", retry.InitialInterval, retry.MaxInterval)
+		}
+		if retry.Multiplier < 1 {
+			return fmt.Errorf("agent config: collector.retry.multiplier must be at least 1, got %v", retry.Multiplier)
+		}
+		if retry.Jitter < 0 || retry.Jitter >= 1 {
+			return fmt.Errorf("agent config: collector.retry.jitter must be in [0, 1), got %v", retry.Jitter)
+		}
+		if retry.MaxElapsedTime <= 0 {
+			return fmt.Errorf("agent config: collector.retry.max_elapsed_time must be greater than 0, got %v", retry.MaxElapsedTime)
+		}
+	}
+
+	breaker := c.Collector.Breaker
+	if breaker.Enabled {
+		if breaker.FailureThreshold <= 0 {
+			return fmt.Errorf("agent config: collector.breaker.failure_threshold must be greater than 0, got %d", breaker.FailureThreshold)
+		}
+		if breaker.OpenTimeout <= 0 {
+			return fmt.Errorf("agent config: collector.breaker.open_timeout must be greater than 0, got %v", breaker.OpenTimeout)
+		}
+	}
+
+	tls := c.Collector.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		return fmt.Errorf("agent config: collector.tls.cert_file and key_file must be set together")
+	}
+	if !tls.Enabled && (tls.CAFile != "" || tls.CertFile != "" || tls.ServerName != "" || tls.InsecureSkipVerify) {
+		return fmt.Errorf("agent config: collector.tls settings are given but collector.tls.enabled is false")
+	}
+
+	if c.Aggregator.Interval <= 0 {
+		return fmt.Errorf("agent config: aggregator.interval must be greater than 0, got %v", c.Aggregator.Interval)
+	}
+	if c.Aggregator.MaxSpans == 0 {
+		return fmt.Errorf("agent config: aggregator.max_spans must be greater than 0")
+	}
+	if c.Aggregator.QueueSize < 0 {
+		return fmt.Errorf("agent config: aggregator.queue_size must not be negative, got %d", c.Aggregator.QueueSize)
+	}
+
+	if c.Exporter.Workers <= 0 {
+		return fmt.Errorf("agent config: exporter.workers must be greater than 0, got %d", c.Exporter.Workers)
+	}
+	if c.Exporter.QueueSize < 0 {
+		return fmt.Errorf("agent config: exporter.queue_size must not be negative, got %d", c.Exporter.QueueSize)
+	}
+
+	if c.WAL.Enabled {
+		if c.WAL.Dir == "" {
+			return fmt.Errorf("agent config: wal.dir is required when the wal is enabled")
+		}
+		if c.WAL.SegmentSize <= 0 {
+			return fmt.Errorf("agent config: wal.segment_size must be greater than 0, got %d", c.WAL.SegmentSize)
+		}
+		if c.WAL.MaxSize < c.WAL.SegmentSize {
+			return fmt.Errorf("agent config: wal.max_size (%d) must be at least wal.segment_size (%d)", c.WAL.MaxSize, c.WAL.SegmentSize)
+		}
+		if c.WAL.ReplayInterval <= 0 {
+			return fmt.Errorf("agent config: wal.replay_interval must be greater than 0, got %v", c.WAL.ReplayInterval)
+		}
+	}
+
+	for key := range c.Tags {
+		if key == "" {
+			return fmt.Errorf("agent config: tags must not have an empty key")
+		}
+	}
+
+	return nil
+}
+
+// validateListenAddrs checks that no two TCP listeners of the agent would bind the same port.
+func (c *Config) validateListenAddrs() error {
+	type listener struct{ name, addr string }
+	listeners := []listener{{"http.addr", c.HTTP.Addr}, {"admin.addr", c.Admin.Addr}}
+	if c.OTLP.Enabled {
+		listeners = append(listeners, listener{"otlp.grpc_addr", c.OTLP.GRPCAddr})
+	}
+
+	for i, a := range listeners {
+		for _, b := range listeners[i+1:] {
+			if a.addr != "" && b.addr != "" && sameListenAddr(a.addr, b.addr) {
+				return fmt.Errorf("agent config: %s %q and %s %q listen on the same address", a.name, a.addr, b.name, b.addr)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameListenAddr reports whether two listen addresses clash: same port, and the same host
+// or a wildcard host (empty, 0.0.0.0 or ::) on either side.
+func sameListenAddr(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	if portA != portB {
+		return false
+	}
+
+	return hostA == hostB || isWildcardHost(hostA) || isWildcardHost(hostB)
+}
+
+func isWildcardHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// Print writes the configuration as YAML.
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// applyFile overrides c with the fields set in the YAML file; missing fields keep their value.
+func (c *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("agent config: read %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("agent config: unsupported file type %q, want .yaml or .yml", filepath.Ext(path))
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("agent config: parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// envName maps a flag name to its environment variable, e.g. collector.tls.ca-file to AGENT_COLLECTOR_TLS_CA_FILE.
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flagName))
+}
+
+// enrichTags returns the static tags sorted by key, so every package gets them in the same order.
+func (c *Config) enrichTags() []config.Tag {
+	keys := make([]string, 0, len(c.Tags))
+	for k := range c.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]config.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, config.Tag{Key: k, Value: c.Tags[k]})
+	}
+
+	return tags
+}
+
+// listValue is a comma separated flag.Value.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	*l = out
+
+	return nil
+}
+
+// tagsValue is a comma separated key=value flag.Value.
+type tagsValue map[string]string
+
+func (t *tagsValue) String() string {
+	if t == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*t))
+	for k, v := range *t {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (t *tagsValue) Set(s string) error {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%q is not key=value", pair)
+		}
+		tags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	*t = tags
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	file := writeConfigFile(t, "agent.yaml", "udp:\n  addr: \":7001\"\n  workers: 2\n")
+	other := writeConfigFile(t, "other.yml", "udp:\n  addr: \":7002\"\n")
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		wantAddr    string
+		wantWorkers int
+	}{
+		{"defaults", nil, nil, ":8888", 4},
+		{"file", nil, []string{"-config", file}, ":7001", 2},
+		{"config from env", map[string]string{"AGENT_CONFIG": file}, nil, ":7001", 2},
+		{"config flag beats env", map[string]string{"AGENT_CONFIG": file}, []string{"-config", other}, ":7002", 4},
+		{"env beats file", map[string]string{"AGENT_UDP_ADDR": ":7003"}, []string{"-config", file}, ":7003", 2},
+		{"flag beats env", map[string]string{"AGENT_UDP_ADDR": ":7003"}, []string{"-config", file, "-udp.addr", ":7004"}, ":7004", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			c, err := LoadConfig(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.UDP.Addr != tt.wantAddr || c.UDP.Workers != tt.wantWorkers {
+				t.Fatalf("udp = %s with %d workers, want %s with %d", c.UDP.Addr, c.UDP.Workers, tt.wantAddr, tt.wantWorkers)
+			}
+		})
+	}
+}
+
+func TestLoadConfigValues(t *testing.T) {
+	t.Setenv("AGENT_TAGS", "region=eu, zone = a")
+	file := writeConfigFile(t, "agent.yaml", "collector:\n  endpoints: [\"a:1\"]\n  retry:\n    max_interval: 20s\n")
+
+	c, err := LoadConfig([]string{"-config", file, "-collector.endpoints", "b:2, c:3,"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"b:2", "c:3"}; !reflect.DeepEqual(c.Collector.Endpoints, want) {
+		t.Errorf("endpoints = %v, want %v", c.Collector.Endpoints, want)
+	}
+	if want := map[string]string{"region": "eu", "zone": "a"}; !reflect.DeepEqual(c.Tags, want) {
+		t.Errorf("tags = %v, want %v", c.Tags, want)
+	}
+	if c.Collector.Retry.MaxInterval != 20*time.Second || c.Collector.Retry.InitialInterval != 500*time.Millisecond {
+		t.Errorf("retry = %+v, want max_interval from the file and the default initial_interval", c.Collector.Retry)
+	}
+	if c.ConfigPath != file {
+		t.Errorf("ConfigPath = %q, want %q", c.ConfigPath, file)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		want    string
+	}{
+		{name: "unknown key", file: "agent.yaml", content: "udp:\n  adr: \":1\"\n", want: "field adr not found"},
+		{name: "unknown section", file: "agent.yaml", content: "exporters: {}\n", want: "field exporters not found"},
+		{name: "wrong type", file: "agent.yaml", content: "udp:\n  workers: many\n", want: "parse"},
+		{name: "unsupported extension", file: "agent.json", content: "{}", want: "unsupported file type"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/agent.yaml"}, want: "read /nonexistent/agent.yaml"},
+		{name: "bad env value", env: map[string]string{"AGENT_UDP_WORKERS": "many"}, want: "AGENT_UDP_WORKERS"},
+		{name: "bad tags", args: []string{"-tags", "region"}, want: `"region" is not key=value`},
+		{name: "unknown flag", args: []string{"-udp.adr", ":1"}, want: "flag provided but not defined"},
+		{name: "invalid result", args: []string{"-udp.workers", "0"}, want: "udp.workers must be greater than 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, tt.file, tt.content)}, args...)
+			}
+
+			_, err := LoadConfig(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("LoadConfig = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEmptyFile(t *testing.T) {
+	c, err := LoadConfig([]string{"-config", writeConfigFile(t, "agent.yaml", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Collector, DefaultConfig().Collector) {
+		t.Fatalf("an empty file changed the defaults: %+v", c.Collector)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+		want   string
+	}{
+		{"udp addr", func(c *Config) { c.UDP.Addr = "" }, "udp.addr is required"},
+		{"udp workers", func(c *Config) { c.UDP.Workers = 0 }, "udp.workers"},
+		{"udp queue size", func(c *Config) { c.UDP.QueueSize = -1 }, "udp.queue_size"},
+		{"udp read buffer", func(c *Config) { c.UDP.ReadBufferSize = -1 }, "udp.read_buffer_size"},
+		{"udp packet size", func(c *Config) { c.UDP.MaxPacketSize = 65536 }, "udp.max_packet_size"},
+		{"http body size", func(c *Config) { c.HTTP.MaxBodySize = 0 }, "http.max_body_size"},
+		{"no endpoints", func(c *Config) { c.Collector.Endpoints = nil }, "at least one collector endpoint"},
+		{"endpoint without port", func(c *Config) { c.Collector.Endpoints = []string{"collector"} }, "must be host:port"},
+		{"policy", func(c *Config) { c.Collector.Balancer.Policy = "random" }, "policy \"random\" is not supported"},
+		{"negative interval", func(c *Config) { c.Collector.Balancer.DNSRefreshInterval = -time.Second }, "intervals must not be negative"},
+		{"health check timeout", func(c *Config) { c.Collector.Balancer.HealthCheckTimeout = 0 }, "health_check_timeout"},
+		{"max failures", func(c *Config) { c.Collector.Balancer.MaxFailures = 0 }, "max_failures"},
+		{"eject duration", func(c *Config) { c.Collector.Balancer.EjectDuration = 0 }, "eject_duration"},
+		{"timeout", func(c *Config) { c.Collector.Timeout = 0 }, "collector.timeout"},
+		{"retry intervals", func(c *Config) { c.Collector.Retry.MaxInterval = time.Millisecond }, "collector.retry intervals"},
+		{"retry multiplier", func(c *Config) { c.Collector.Retry.Multiplier = 0.5 }, "collector.retry.multiplier"},
+		{"retry jitter", func(c *Config) { c.Collector.Retry.Jitter = 1 }, "collector.retry.jitter"},
+		{"retry elapsed time", func(c *Config) { c.Collector.Retry.MaxElapsedTime = 0 }, "collector.retry.max_elapsed_time"},
+		{"breaker threshold", func(c *Config) { c.Collector.Breaker.FailureThreshold = 0 }, "collector.breaker.failure_threshold"},
+		{"breaker timeout", func(c *Config) { c.Collector.Breaker.OpenTimeout = 0 }, "collector.breaker.open_timeout"},
+		{"tls key without cert", func(c *Config) {
+			c.Collector.TLS.Enabled = true
+			c.Collector.TLS.KeyFile = "client.key"
+		}, "cert_file and key_file must be set together"},
+		{"tls settings while disabled", func(c *Config) { c.Collector.TLS.CAFile = "ca.pem" }, "collector.tls.enabled is false"},
+		{"aggregator interval", func(c *Config) { c.Aggregator.Interval = 0 }, "aggregator.interval"},
+		{"aggregator max spans", func(c *Config) { c.Aggregator.MaxSpans = 0 }, "aggregator.max_spans"},
+		{"aggregator queue size", func(c *Config) { c.Aggregator.QueueSize = -1 }, "aggregator.queue_size"},
+		{"exporter workers", func(c *Config) { c.Exporter.Workers = 0 }, "exporter.workers"},
+		{"exporter queue size", func(c *Config) { c.Exporter.QueueSize = -1 }, "exporter.queue_size"},
+		{"wal dir", func(c *Config) {
+			c.WAL.Enabled = true
+			c.WAL.Dir = ""
+		}, "wal.dir is required"},
+		{"wal segment size", func(c *Config) {
+			c.WAL.Enabled = true
+			c.WAL.SegmentSize = 0
+		}, "wal.segment_size"},
+		{"wal max size", func(c *Config) {
+			c.WAL.Enabled = true
+			c.WAL.MaxSize = c.WAL.SegmentSize - 1
+		}, "wal.max_size"},
+		{"wal replay interval", func(c *Config) {
+			c.WAL.Enabled = true
+			c.WAL.ReplayInterval = 0
+		}, "wal.replay_interval"},
+		{"empty tag key", func(c *Config) { c.Tags = map[string]string{"": "x"} }, "empty key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			tt.mutate(c)
+			err := c.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("DefaultConfig is invalid: %v", err)
+	}
+}
+
+func TestValidateListenAddrs(t *testing.T) {
+	tests := []struct {
+		name              string
+		http, admin, grpc string
+		otlpDisabled      bool
+		wantErr           bool
+	}{
+		{name: "defaults", http: ":8889", admin: ":8890", grpc: ":4317"},
+		{name: "same string", http: ":8889", admin: ":8889", wantErr: true},
+		{name: "empty host and 0.0.0.0", http: ":8889", admin: "0.0.0.0:8889", wantErr: true},
+		{name: "ipv6 wildcard and a host", http: "[::]:8889", admin: "127.0.0.1:8889", wantErr: true},
+		{name: "two hosts on one port", http: "127.0.0.1:8889", admin: "127.0.0.2:8889"},
+		{name: "different ports", http: "0.0.0.0:8889", admin: "0.0.0.0:8890"},
+		{name: "grpc and http", http: ":4317", admin: ":8890", grpc: "0.0.0.0:4317", wantErr: true},
+		{name: "grpc and admin", http: ":8889", admin: "localhost:4317", grpc: ":4317", wantErr: true},
+		{name: "grpc ignored when otlp is off", http: ":4317", admin: ":8890", grpc: ":4317", otlpDisabled: true},
+		{name: "disabled listeners", http: "", admin: "", grpc: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			c.HTTP.Addr, c.Admin.Addr, c.OTLP.GRPCAddr = tt.http, tt.admin, tt.grpc
+			c.OTLP.Enabled = !tt.otlpDisabled
+
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
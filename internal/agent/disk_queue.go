@@ -0,0 +1,384 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	pb "tracer/internal/proto"
+)
+
+const (
+	segmentExt = ".wal"
+	// cursorFile keeps the position of the first record that has not been committed.
+	cursorFile = "cursor"
+	// recordHeaderSize is the payload length and its CRC32, both uint32 big endian.
+	recordHeaderSize = 8
+)
+
+// ErrDiskQueueFull is returned by Append when the record would exceed the max disk size.
+var ErrDiskQueueFull = errors.New("disk queue: max size reached")
+
+// errCorruptRecord marks a record whose header, checksum or payload cannot be trusted.
+var errCorruptRecord = errors.New("disk queue: corrupt record")
+
+// DiskQueue is a write-ahead queue of batches on disk, used while the collector is unreachable.
+// Records are appended to numbered segment files and read back in the order they were written.
+// A segment is deleted once every record in it has been committed, so the queue survives restarts.
+// Commit saves the read position to a cursor file, so committed records of a partly drained segment
+// are not sent again after a restart. Delivery is still at-least-once: the record in flight at a crash,
+// and the sent parts of a record kept with Replace, are sent again.
+type DiskQueue struct {
+	mu          sync.Mutex
+	dir         string
+	maxSize     int64
+	segmentSize int64
+
+	// segments are the sequence numbers on disk, oldest first; the last one may be the write segment.
+	segments []uint64
+	size     int64
+	nextSeq  uint64
+
+	writer     *os.File
+	writerSeq  uint64
+	writerSize int64
+
+	reader    *os.File
+	bufReader *bufio.Reader
+	readerSeq uint64
+	// readOffset is where the next record of the read segment starts; pendingEnd is where pending ends.
+	readOffset int64
+	pendingEnd int64
+	pending    *pb.BatchPackage
+
+	// cursor loaded at startup: the read position in segment cursorSeq.
+	cursorSeq    uint64
+	cursorOffset int64
+
+	corrupted uint64
+}
+
+// NewDiskQueue opens the queue in conf.Dir, picking up the segments left by a previous run.
+func NewDiskQueue(conf WALConfig) (*DiskQueue, error) {
+	q := new(DiskQueue)
+	if err := q.init(conf); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// init creates the directory and loads the existing segments.
+func (q *DiskQueue) init(conf WALConfig) error {
+	if err := os.MkdirAll(conf.Dir, 0o755); err != nil {
+		return fmt.Errorf("disk queue: %w", err)
+	}
+
+	entries, err := os.ReadDir(conf.Dir)
+	if err != nil {
+		return fmt.Errorf("disk queue: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("disk queue: %w", err)
+		}
+
+		q.segments = append(q.segments, seq)
+		q.size += info.Size()
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	q.dir = conf.Dir
+	q.maxSize = conf.MaxSize
+	q.segmentSize = conf.SegmentSize
+
+	if data, err := os.ReadFile(filepath.Join(conf.Dir, cursorFile)); err == nil {
+		if _, err := fmt.Sscan(string(data), &q.cursorSeq, &q.cursorOffset); err != nil {
+			log.Println("disk queue: ignoring invalid cursor:", err)
+			q.cursorSeq, q.cursorOffset = 0, 0
+		}
+	}
+
+	// 新 segment 的编号要大于 cursor 指向的 segment，旧 cursor 才不会落到同号的新文件上
+	q.nextSeq = q.cursorSeq + 1
+	if n := len(q.segments); n != 0 && q.segments[n-1] >= q.nextSeq {
+		q.nextSeq = q.segments[n-1] + 1
+	}
+
+	return nil
+}
+
+// Append writes the batch at the end of the queue and syncs it to disk.
+func (q *DiskQueue) Append(batch *pb.BatchPackage) error {
+	payload, err := proto.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("disk queue: %w", err)
+	}
+
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.size+int64(len(record)) > q.maxSize {
+		return ErrDiskQueueFull
+	}
+
+	if q.writer == nil || (q.writerSize > 0 && q.writerSize+int64(len(record)) > q.segmentSize) {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := q.writer.Write(record)
+	q.writerSize += int64(n)
+	q.size += int64(n)
+	if err != nil {
+		// 写了一半的记录读的时候会被当成损坏跳过，这里换一个新 segment 继续写
+		q.closeWriter()
+		return fmt.Errorf("disk queue: %w", err)
+	}
+
+	if err := q.writer.Sync(); err != nil {
+		return fmt.Errorf("disk queue: %w", err)
+	}
+
+	return nil
+}
+
+// Next returns the oldest batch that has not been committed, or false when the queue is empty.
+// Calling Next again without Commit returns the same batch.
+func (q *DiskQueue) Next() (*pb.BatchPackage, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.pending == nil {
+		if q.reader == nil {
+			if len(q.segments) == 0 {
+				return nil, false, nil
+			}
+
+			seq := q.segments[0]
+			if q.writer != nil && seq == q.writerSeq {
+				if q.writerSize == 0 {
+					return nil, false, nil
+				}
+				// 只剩正在写的 segment，封存后开始读
+				q.closeWriter()
+			}
+
+			f, err := os.Open(q.segmentPath(seq))
+			if err != nil {
+				return nil, false, fmt.Errorf("disk queue: %w", err)
+			}
+			q.readOffset = 0
+			if seq == q.cursorSeq && q.cursorOffset > 0 {
+				if _, err := f.Seek(q.cursorOffset, io.SeekStart); err != nil {
+					_ = f.Close()
+					return nil, false, fmt.Errorf("disk queue: %w", err)
+				}
+				q.readOffset = q.cursorOffset
+			}
+			// cursor 只在启动后第一次打开它的 segment 时使用
+			q.cursorSeq, q.cursorOffset = 0, 0
+			q.reader = f
+			q.bufReader = bufio.NewReader(f)
+			q.readerSeq = seq
+		}
+
+		batch, n, err := q.readRecord()
+		switch {
+		case err == nil:
+			q.pending = batch
+			q.pendingEnd = q.readOffset + n
+		case errors.Is(err, io.EOF):
+			q.removeReadSegment()
+		default:
+			q.corrupted++
+			log.Printf("disk queue: skipping the rest of segment %d: %v", q.readerSeq, err)
+			q.removeReadSegment()
+		}
+	}
+
+	return q.pending, true, nil
+}
+
+// Commit drops the batch returned by the last Next and saves the read position.
+func (q *DiskQueue) Commit() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.pending == nil {
+		return
+	}
+	q.pending = nil
+	q.readOffset = q.pendingEnd
+	q.saveCursor()
+}
+
+// Replace keeps batch at the head of the queue instead of the one returned by the last Next,
+// e.g. the parts of it that still have to be sent. It lives in memory only: after a restart
+// the whole original record is replayed.
+func (q *DiskQueue) Replace(batch *pb.BatchPackage) {
+	q.mu.Lock()
+	if q.pending != nil {
+		q.pending = batch
+	}
+	q.mu.Unlock()
+}
+
+// Size returns the bytes used on disk.
+func (q *DiskQueue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.size
+}
+
+// Corrupted returns how many corrupt segments were skipped.
+func (q *DiskQueue) Corrupted() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.corrupted
+}
+
+// Close closes the open segment files. The data stays on disk for the next run.
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closeWriter()
+	if q.reader != nil {
+		err := q.reader.Close()
+		q.reader = nil
+		return err
+	}
+
+	return nil
+}
+
+// readRecord reads the next record of the read segment and returns it with its size on disk.
+// io.EOF means the segment ended cleanly on a record boundary.
+func (q *DiskQueue) readRecord() (*pb.BatchPackage, int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(q.bufReader, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if int64(length) > q.maxSize {
+		return nil, 0, fmt.Errorf("%w: length %d", errCorruptRecord, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(q.bufReader, payload); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+	}
+
+	batch := new(pb.BatchPackage)
+	if err := proto.Unmarshal(payload, batch); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+
+	return batch, recordHeaderSize + int64(length), nil
+}
+
+// saveCursor writes the read position next to the segments. q.mu must be held.
+// The file is replaced with a rename, so a crash leaves either the old or the new cursor.
+func (q *DiskQueue) saveCursor() {
+	path := filepath.Join(q.dir, cursorFile)
+	data := fmt.Sprintf("%d %d\n", q.readerSeq, q.readOffset)
+	if err := os.WriteFile(path+".tmp", []byte(data), 0o644); err != nil {
+		log.Println("disk queue: save cursor:", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Println("disk queue: save cursor:", err)
+	}
+}
+
+// rotate closes the write segment and opens the next one.
+func (q *DiskQueue) rotate() error {
+	q.closeWriter()
+
+	seq := q.nextSeq
+	f, err := os.OpenFile(q.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("disk queue: %w", err)
+	}
+
+	q.nextSeq++
+	q.writer = f
+	q.writerSeq = seq
+	q.writerSize = 0
+	q.segments = append(q.segments, seq)
+
+	return nil
+}
+
+func (q *DiskQueue) closeWriter() {
+	if q.writer == nil {
+		return
+	}
+	if err := q.writer.Close(); err != nil {
+		log.Println("disk queue:", err)
+	}
+	q.writer = nil
+}
+
+// removeReadSegment deletes the segment being read, which is always the oldest one.
+func (q *DiskQueue) removeReadSegment() {
+	path := q.segmentPath(q.readerSeq)
+	if err := q.reader.Close(); err != nil {
+		log.Println("disk queue:", err)
+	}
+	q.reader = nil
+	q.bufReader = nil
+
+	if info, err := os.Stat(path); err == nil {
+		q.size -= info.Size()
+	}
+	if err := os.Remove(path); err != nil {
+		log.Println("disk queue:", err)
+	}
+	q.segments = q.segments[1:]
+
+	// cursor 指向的 segment 已经不在了
+	if err := os.Remove(filepath.Join(q.dir, cursorFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println("disk queue:", err)
+	}
+}
+
+func (q *DiskQueue) segmentPath(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
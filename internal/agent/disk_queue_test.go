@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	pb "tracer/internal/proto"
+)
+
+func newTestDiskQueue(t *testing.T, dir string) *DiskQueue {
+	t.Helper()
+	q, err := NewDiskQueue(WALConfig{Dir: dir, MaxSize: 1 << 20, SegmentSize: 1 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func walBatch(service string) *pb.BatchPackage {
+	return &pb.BatchPackage{Packages: []*pb.Package{{Process: &pb.Process{ServiceName: service}}}}
+}
+
+func appendAll(t *testing.T, q *DiskQueue, services ...string) {
+	t.Helper()
+	for _, service := range services {
+		if err := q.Append(walBatch(service)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// drain commits every batch and returns their service names in order.
+func drain(t *testing.T, q *DiskQueue, limit int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < limit {
+		batch, ok, err := q.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		got = append(got, batch.Packages[0].Process.ServiceName)
+		q.Commit()
+	}
+	return got
+}
+
+func TestDiskQueueOrder(t *testing.T) {
+	q := newTestDiskQueue(t, t.TempDir())
+	defer q.Close()
+
+	appendAll(t, q, "a", "b", "c")
+	if got := drain(t, q, 10); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Fatalf("drained %v, want [a b c]", got)
+	}
+	if q.Size() != 0 {
+		t.Fatalf("size = %d after draining, want 0", q.Size())
+	}
+}
+
+func TestDiskQueueNextWithoutCommit(t *testing.T) {
+	q := newTestDiskQueue(t, t.TempDir())
+	defer q.Close()
+
+	appendAll(t, q, "a", "b")
+	first, _, _ := q.Next()
+	again, _, _ := q.Next()
+	if first != again {
+		t.Fatal("Next without Commit returned a different batch")
+	}
+
+	q.Replace(walBatch("rest"))
+	if got := drain(t, q, 10); !slices.Equal(got, []string{"rest", "b"}) {
+		t.Fatalf("drained %v, want [rest b]", got)
+	}
+}
+
+func TestDiskQueueRestartResumesAtCursor(t *testing.T) {
+	dir := t.TempDir()
+
+	q := newTestDiskQueue(t, dir)
+	appendAll(t, q, "a", "b", "c")
+	if got := drain(t, q, 1); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("drained %v, want [a]", got)
+	}
+	// b is read but not committed when the agent stops
+	if _, _, err := q.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q = newTestDiskQueue(t, dir)
+	defer q.Close()
+	if got := drain(t, q, 10); !slices.Equal(got, []string{"b", "c"}) {
+		t.Fatalf("replayed %v, want [b c]", got)
+	}
+}
+
+func TestDiskQueueRestartAfterFullDrain(t *testing.T) {
+	dir := t.TempDir()
+
+	q := newTestDiskQueue(t, dir)
+	appendAll(t, q, "a", "b")
+	if got := drain(t, q, 10); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("drained %v, want [a b]", got)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q = newTestDiskQueue(t, dir)
+	appendAll(t, q, "c", "d", "e")
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q = newTestDiskQueue(t, dir)
+	defer q.Close()
+	if got := drain(t, q, 10); !slices.Equal(got, []string{"c", "d", "e"}) {
+		t.Fatalf("replayed %v, want [c d e]", got)
+	}
+}
+
+func TestDiskQueueCursorUsedOnce(t *testing.T) {
+	dir := t.TempDir()
+
+	q := newTestDiskQueue(t, dir)
+	appendAll(t, q, "a", "b")
+	drain(t, q, 1)
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the restarted queue drains its segment, writes a new one and reads it from the start
+	q = newTestDiskQueue(t, dir)
+	defer q.Close()
+	if got := drain(t, q, 10); !slices.Equal(got, []string{"b"}) {
+		t.Fatalf("replayed %v, want [b]", got)
+	}
+	appendAll(t, q, "c", "d")
+	if got := drain(t, q, 10); !slices.Equal(got, []string{"c", "d"}) {
+		t.Fatalf("drained %v, want [c d]", got)
+	}
+}
+
+func TestDiskQueueFull(t *testing.T) {
+	q, err := NewDiskQueue(WALConfig{Dir: t.TempDir(), MaxSize: 16, SegmentSize: 1 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if err := q.Append(walBatch("a-service-name-longer-than-the-queue")); err != ErrDiskQueueFull {
+		t.Fatalf("Append = %v, want ErrDiskQueueFull", err)
+	}
+}
+
+// firstSegment returns the path of the oldest segment in dir.
+func firstSegment(t *testing.T, dir string) string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no segment in %s: %v", dir, err)
+	}
+	sort.Strings(paths)
+	return paths[0]
+}
+
+func TestDiskQueueSkipsCorruptRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, path string)
+		want    []string
+	}{
+		{
+			name: "flipped payload byte",
+			corrupt: func(t *testing.T, path string) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data[recordHeaderSize] ^= 0xff
+				if err := os.WriteFile(path, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			// the checksum fails on a, and the rest of its segment is skipped
+			want: []string{"c"},
+		},
+		{
+			name: "truncated last record",
+			corrupt: func(t *testing.T, path string) {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Truncate(path, info.Size()-1); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"a", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// a and b share the first segment; c goes to a new one after the restart
+			q := newTestDiskQueue(t, dir)
+			appendAll(t, q, "a", "b")
+			if err := q.Close(); err != nil {
+				t.Fatal(err)
+			}
+			q = newTestDiskQueue(t, dir)
+			appendAll(t, q, "c")
+			if err := q.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.corrupt(t, firstSegment(t, dir))
+
+			q = newTestDiskQueue(t, dir)
+			defer q.Close()
+			if got := drain(t, q, 10); !slices.Equal(got, tt.want) {
+				t.Fatalf("drained %v, want %v", got, tt.want)
+			}
+			if q.Corrupted() != 1 {
+				t.Fatalf("Corrupted = %d, want 1", q.Corrupted())
+			}
+			if q.Size() != 0 {
+				t.Fatalf("size = %d after draining, want 0", q.Size())
+			}
+		})
+	}
+}
@@ -2,15 +2,19 @@ package agent
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"log"
+	"os"
 	"time"
 	pb "tracer/internal/proto"
 	"tracer/pkg/config"
+	"tracer/pkg/metrics"
 	"tracer/pkg/model"
 	"tracer/pkg/span"
 )
@@ -20,13 +24,21 @@ type Exporter struct {
 	ctx       context.Context
 	WorkerNum int
 	batchCh   <-chan model.BatchPackage
-	client    pb.CollectorServiceClient
+	balancer  *Balancer
+	timeout   time.Duration
+
+	retry   RetryConfig
+	metrics *metrics.AgentMetrics
+
+	// queue keeps batches that could not be exported; nil when the WAL is disabled.
+	queue          *DiskQueue
+	replayInterval time.Duration
 }
 
-// NewExporter creates a new Exporter.
-func NewExporter(workNum int, batchCh <-chan model.BatchPackage) (*Exporter, error) {
+// NewExporter creates a new Exporter connected to the collector in conf.
+func NewExporter(conf *Config, batchCh <-chan model.BatchPackage) (*Exporter, error) {
 	e := new(Exporter)
-	if err := e.init(workNum, batchCh); err != nil {
+	if err := e.init(conf, batchCh); err != nil {
 		return nil, err
 	}
 
@@ -34,27 +46,51 @@ func NewExporter(workNum int, batchCh <-chan model.BatchPackage) (*Exporter, err
 }
 
 // init initializes the Exporter with gRPC connection and worker count.
-func (e *Exporter) init(workNum int, batchCh <-chan model.BatchPackage) error {
+func (e *Exporter) init(conf *Config, batchCh <-chan model.BatchPackage) error {
 	e.ctx = context.Background()
-	conn, err := grpc.NewClient("localhost:50051",
-		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	creds, err := transportCredentials(conf.Collector.TLS)
+	if err != nil {
+		return err
+	}
 
+	e.balancer, err = NewBalancer(conf, creds)
 	if err != nil {
 		return err
 	}
 
-	e.client = pb.NewCollectorServiceClient(conn)
-	e.WorkerNum = workNum
+	e.WorkerNum = conf.Exporter.Workers
 	e.batchCh = batchCh
+	e.timeout = conf.Collector.Timeout
+	e.retry = conf.Collector.Retry
+	e.metrics = metrics.NewAgentMetrics(conf.Metrics)
+
+	if conf.WAL.Enabled {
+		e.queue, err = NewDiskQueue(conf.WAL)
+		if err != nil {
+			return err
+		}
+		e.replayInterval = conf.WAL.ReplayInterval
+	}
 
 	return nil
 }
 
+// Ready reports whether batches can be exported now: at least one collector is usable
+// and its circuit breaker is not open.
+func (e *Exporter) Ready() bool {
+	return e.balancer.Ready()
+}
+
 // Start launches the worker goroutines to consume batches.
 func (e *Exporter) Start() {
+	e.balancer.Start(e.ctx)
 	for i := 0; i < e.WorkerNum; i++ {
 		go e.Consume()
 	}
+
+	if e.queue != nil {
+		go e.replay()
+	}
 }
 
 // Consume reads batches from the channel and exports them via gRPC.
@@ -65,18 +101,197 @@ func (e *Exporter) Consume() {
 			log.Println("exit")
 			return
 		case batch := <-e.batchCh:
-			ctx, cancel := context.WithTimeout(e.ctx, 5*time.Second)
-			resp, err := e.client.Export(ctx, e.BatchToModel(batch))
-			if err != nil {
-				log.Println(err)
+			for _, part := range e.balancer.Split(e.BatchToModel(batch)) {
+				if err := e.export(part); err != nil {
+					log.Println(err)
+					e.keepOrDrop(part.req, err)
+				}
 			}
+		}
+	}
+
+}
+
+// export sends one batch, retrying retryable failures with backoff until MaxElapsedTime.
+func (e *Exporter) export(req routedBatch) error {
+	err := e.send(req)
+	if err == nil || !e.retry.Enabled {
+		return err
+	}
+
+	start := time.Now()
+	b := newBackoff(e.retry)
+	for isRetryable(err) {
+		delay := serverRetryDelay(err)
+		if delay == 0 {
+			delay = b.next()
+		}
+		if time.Since(start)+delay > e.retry.MaxElapsedTime {
+			return err
+		}
 
-			cancel()
+		select {
+		case <-e.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		e.metrics.ExportRetries.Inc(1)
+		if err = e.send(req); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// send makes one Export call to the collector picked by the balancer, unless its circuit breaker is open.
+// The breaker is asked after Pick, so every call it allows ends in Success or Failure.
+func (e *Exporter) send(req routedBatch) error {
+	be := e.balancer.Pick(req.key)
+	if be == nil {
+		return errNoCollector
+	}
+	if !be.breaker.Allow() {
+		e.balancer.Release(be)
+		return errBreakerOpen
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(e.ctx, e.timeout)
+	_, err := be.client.Export(ctx, req.req)
+	cancel()
+	e.metrics.ExportLatency.Record(time.Since(start))
+	e.balancer.Done(be, err)
+
+	if err != nil {
+		e.metrics.ExportErrors.Inc(1)
+		if isCollectorFailure(err) {
+			be.breaker.Failure()
+		} else {
+			// collector 有响应，只是拒绝了这个 batch
+			be.breaker.Success()
+		}
+		return err
+	}
+
+	e.metrics.ExportSuccess.Inc(1)
+	be.breaker.Success()
+
+	return nil
+}
+
+// Spill keeps a batch that could not be handed to the workers, e.g. when the aggregator output is full.
+// Without a WAL the batch is dropped.
+func (e *Exporter) Spill(batch model.BatchPackage) {
+	e.spill(e.BatchToModel(batch))
+}
+
+// keepOrDrop spills a batch that failed for a temporary reason and drops one the collector rejected,
+// since sending it again would fail again and block the on-disk queue.
+func (e *Exporter) keepOrDrop(req *pb.BatchPackage, err error) {
+	if isTemporary(err) {
+		e.spill(req)
+		return
+	}
+
+	e.metrics.ExportRejected.Inc(1)
+	log.Println("exporter: collector rejected batch, dropping it:", err)
+}
+
+func (e *Exporter) spill(req *pb.BatchPackage) {
+	if e.queue == nil {
+		log.Println("exporter: no wal configured, dropping batch")
+		return
+	}
+
+	if err := e.queue.Append(req); err != nil {
+		log.Println("exporter: dropping batch:", err)
+	}
+}
+
+// replay periodically sends the queued batches in order, stopping at the first failure.
+func (e *Exporter) replay() {
+	ticker := time.NewTicker(e.replayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			e.drain()
+		}
+	}
+}
+
+// drain exports queued batches in order until the queue is empty or the collector fails.
+// Rejected parts are dropped; parts that failed for a temporary reason stay at the head of the queue.
+func (e *Exporter) drain() {
+	for {
+		req, ok, err := e.queue.Next()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		remaining := new(pb.BatchPackage)
+		for _, part := range e.balancer.Split(req) {
+			err := e.send(part)
+			switch {
+			case err == nil:
+			case isTemporary(err):
+				remaining.Packages = append(remaining.Packages, part.req.Packages...)
+			default:
+				e.metrics.ExportRejected.Inc(1)
+				log.Println("exporter: collector rejected queued batch, dropping it:", err)
+			}
+		}
+
+		if len(remaining.Packages) != 0 {
+			// 只保留没发出去的部分，下次从这里继续，顺序不变
+			e.queue.Replace(remaining)
+			return
+		}
+		e.queue.Commit()
+	}
+}
+
+// transportCredentials builds the gRPC credentials for the collector connection.
+func transportCredentials(conf TLSConfig) (credentials.TransportCredentials, error) {
+	if !conf.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("exporter: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("exporter: no certificate found in %s", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
 
-			log.Println(resp)
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("exporter: load client certificate: %w", err)
 		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
+	return credentials.NewTLS(tlsConfig), nil
 }
 
 // BatchToModel converts the internal batch package to the protobuf BatchPackage.
@@ -121,6 +336,7 @@ func convertSpans(spans []span.ToModel) []*pb.SpanModel {
 				Baggage:  s.Context.Baggage,
 				Sampled:  s.Context.Sampled,
 				ParentId: s.Context.ParentID,
+				Debug:    s.Context.Debug,
 			},
 			Tags:      tagsToMap(s.Tags),
 			StartTime: timestamppb.New(s.StartTime),
@@ -130,9 +346,10 @@ func convertSpans(spans []span.ToModel) []*pb.SpanModel {
 		// 转换 References
 		for _, ref := range s.References {
 			sm.References = append(sm.References, &pb.Reference{
-				TraceId: ref.TraceID,
-				SpanId:  ref.SpanID,
-				RefType: ref.RefType,
+				TraceId:    ref.TraceID,
+				SpanId:     ref.SpanID,
+				RefType:    ref.RefType,
+				Attributes: tagsToMap(ref.Attributes),
 			})
 		}
 
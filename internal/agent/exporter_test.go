@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"sync/atomic"
+	"testing"
+	"time"
+	pb "tracer/internal/proto"
+	"tracer/pkg/metrics"
+)
+
+// flakyCollector answers Export with Unavailable while fail is set.
+type flakyCollector struct {
+	pb.UnimplementedCollectorServiceServer
+	fail  atomic.Bool
+	calls atomic.Int64
+}
+
+func (c *flakyCollector) Export(context.Context, *pb.BatchPackage) (*pb.ExportResponse, error) {
+	c.calls.Add(1)
+	if c.fail.Load() {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+	return &pb.ExportResponse{Success: true}, nil
+}
+
+// newBreakerExporter returns an exporter whose breakers open after one failure and stay open
+// until the test moves them on. Ejection is out of the way.
+func newBreakerExporter(t *testing.T, endpoints ...string) *Exporter {
+	t.Helper()
+	conf := DefaultConfig()
+	conf.Collector.Endpoints = endpoints
+	conf.Collector.Balancer.MaxFailures = 1000
+	conf.Collector.Breaker = BreakerConfig{Enabled: true, FailureThreshold: 1, OpenTimeout: time.Hour}
+
+	b, err := NewBalancer(conf, insecure.NewCredentials())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.checkHealth(context.Background())
+	return &Exporter{
+		ctx:      context.Background(),
+		balancer: b,
+		timeout:  5 * time.Second,
+		metrics:  metrics.NewAgentMetrics(nil),
+	}
+}
+
+func backendFor(t *testing.T, b *Balancer, addr string) *backend {
+	t.Helper()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, be := range b.backends {
+		if be.addr == addr {
+			return be
+		}
+	}
+	t.Fatalf("no backend for %s", addr)
+	return nil
+}
+
+func TestExporterBreakerPerCollector(t *testing.T) {
+	bad, good := &flakyCollector{}, &flakyCollector{}
+	bad.fail.Store(true)
+	badAddr, goodAddr := startCollector(t, bad), startCollector(t, good)
+	e := newBreakerExporter(t, badAddr, goodAddr)
+
+	var failed int
+	for i := 0; i < 10; i++ {
+		if err := e.send(routedBatch{req: &pb.BatchPackage{}}); err != nil {
+			failed++
+		}
+	}
+	if failed != 1 || bad.calls.Load() != 1 || good.calls.Load() != 9 {
+		t.Fatalf("failed = %d, bad calls = %d, good calls = %d; want the bad collector cut off after one failure",
+			failed, bad.calls.Load(), good.calls.Load())
+	}
+	if !e.Ready() {
+		t.Fatal("exporter not ready although the good collector's breaker is closed")
+	}
+
+	// after OpenTimeout the bad collector gets one probe, which closes its breaker
+	bad.fail.Store(false)
+	breaker := backendFor(t, e.balancer, badAddr).breaker
+	breaker.openedAt = breaker.openedAt.Add(-2 * time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := e.send(routedBatch{req: &pb.BatchPackage{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bad.calls.Load() != 2 || breaker.State() != metrics.BreakerClosed {
+		t.Fatalf("bad calls = %d, breaker state = %d, want a probe that closed the breaker", bad.calls.Load(), breaker.State())
+	}
+}
+
+func TestExporterBreakerAllOpen(t *testing.T) {
+	c := &flakyCollector{}
+	c.fail.Store(true)
+	addr := startCollector(t, c)
+	e := newBreakerExporter(t, addr)
+	be := backendFor(t, e.balancer, addr)
+
+	if err := e.send(routedBatch{req: &pb.BatchPackage{}}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("first send = %v, want Unavailable", err)
+	}
+	if err := e.send(routedBatch{req: &pb.BatchPackage{}}); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("send with the breaker open = %v, want errBreakerOpen", err)
+	}
+	if n := be.inflight.Load(); n != 0 {
+		t.Fatalf("inflight = %d after a call the breaker refused, want 0", n)
+	}
+	if e.Ready() {
+		t.Fatal("exporter ready although the only breaker is open")
+	}
+
+	// a failed probe opens the breaker again instead of leaving it half-open
+	be.breaker.openedAt = be.breaker.openedAt.Add(-2 * time.Hour)
+	if err := e.send(routedBatch{req: &pb.BatchPackage{}}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("probe = %v, want Unavailable", err)
+	}
+	if state := be.breaker.State(); state != metrics.BreakerOpen {
+		t.Fatalf("breaker state = %d after a failed probe, want open", state)
+	}
+	if c.calls.Load() != 2 {
+		t.Fatalf("collector calls = %d, want the first call and the probe", c.calls.Load())
+	}
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"tracer/pkg/model"
+)
+
+// PackagesPath accepts one model.Package per request, the same payload the SDK sends over UDP.
+const PackagesPath = "/v1/packages"
+
+// HTTPReceiver is the HTTP intake of the agent, for runtimes that cannot send UDP.
+// Packages go through the same Enrich step and into the same aggregator channel as the UDP intake.
+type HTTPReceiver struct {
+	server      *http.Server
+	mux         *http.ServeMux
+	buffer      *Buffer
+	maxBodySize int64
+}
+
+// NewHTTPReceiver creates a receiver listening on conf.HTTP.Addr that hands packages to buffer.
+func NewHTTPReceiver(conf *Config, buffer *Buffer) *HTTPReceiver {
+	r := &HTTPReceiver{
+		mux:         http.NewServeMux(),
+		buffer:      buffer,
+		maxBodySize: conf.HTTP.MaxBodySize,
+	}
+	r.server = &http.Server{
+		Addr:              conf.HTTP.Addr,
+		Handler:           r.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	r.mux.HandleFunc(PackagesPath, r.handlePackage)
+	if conf.OTLP.Enabled {
+		r.mux.HandleFunc(OTLPTracesPath, r.handleOTLP)
+	}
+	if conf.Zipkin.Enabled {
+		r.mux.HandleFunc(ZipkinSpansPath, r.handleZipkin)
+	}
+
+	return r
+}
+
+// Handle registers another intake endpoint on the same listener.
+func (r *HTTPReceiver) Handle(pattern string, handler http.Handler) {
+	r.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP makes the receiver usable in tests and behind another server.
+func (r *HTTPReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// ListenAndServe serves until Close is called, then returns nil.
+func (r *HTTPReceiver) ListenAndServe() error {
+	log.Println("agent HTTP intake listening on", r.server.Addr)
+	if err := r.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Serve is ListenAndServe on an existing listener.
+func (r *HTTPReceiver) Serve(lis net.Listener) error {
+	if err := r.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Close stops the listener.
+func (r *HTTPReceiver) Close() error {
+	return r.server.Close()
+}
+
+// handlePackage answers 202 when the package was queued and 429 when the aggregator is full.
+func (r *HTTPReceiver) handlePackage(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 以后支持二进制格式时按 Content-Type 分发
+	if mediaType := contentType(req); mediaType != "application/json" {
+		http.Error(w, fmt.Sprintf("unsupported content type %q, want application/json", mediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, status, err := r.readBody(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var pkg model.Package
+	if err := json.Unmarshal(body, &pkg); err != nil {
+		r.buffer.recordDecodeError(senderIP(req))
+		http.Error(w, "invalid package: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.accept(w, pkg)
+}
+
+// accept enriches the package and offers it to the aggregator.
+func (r *HTTPReceiver) accept(w http.ResponseWriter, pkg model.Package) {
+	if !r.buffer.Offer(r.buffer.Enrich(pkg)) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "aggregator is full, retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// readBody reads the request body, gunzipping it if needed, and enforces the size limit
+// on both the compressed and the decompressed size.
+func (r *HTTPReceiver) readBody(w http.ResponseWriter, req *http.Request) ([]byte, int, error) {
+	var reader io.Reader = http.MaxBytesReader(w, req.Body, r.maxBodySize)
+
+	switch strings.ToLower(req.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding %q", req.Header.Get("Content-Encoding"))
+	}
+
+	// 多读一个字节用来判断解压后是否超限
+	body, err := io.ReadAll(io.LimitReader(reader, r.maxBodySize+1))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("body larger than %d bytes", r.maxBodySize)
+		}
+		return nil, http.StatusBadRequest, err
+	}
+	if int64(len(body)) > r.maxBodySize {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("body larger than %d bytes", r.maxBodySize)
+	}
+
+	r.buffer.recordReceived()
+
+	return body, http.StatusOK, nil
+}
+
+// contentType returns the media type without parameters; an empty header counts as JSON.
+func contentType(req *http.Request) string {
+	header := req.Header.Get("Content-Type")
+	if header == "" {
+		return "application/json"
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return header
+	}
+
+	return mediaType
+}
+
+func senderIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
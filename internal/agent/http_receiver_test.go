@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tracer/internal/testutil"
+	"tracer/pkg/model"
+)
+
+const testPackage = `{"Process":{"ServiceName":"shop"},"Spans":[]}`
+
+// newTestReceiver returns a receiver whose aggregator channel holds queue packages.
+func newTestReceiver(t *testing.T, queue int, maxBodySize int64) (*HTTPReceiver, *Buffer, chan model.Package) {
+	t.Helper()
+	conf := DefaultConfig()
+	conf.UDP.Addr = "127.0.0.1:0"
+	conf.HTTP.MaxBodySize = maxBodySize
+
+	batchCh := make(chan model.Package, queue)
+	buffer, err := NewBuffer(conf, batchCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = buffer.Close() })
+
+	return NewHTTPReceiver(conf, buffer), buffer, batchCh
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHTTPReceiverPackages(t *testing.T) {
+	// 压缩后很小，解压后超过上限
+	padded := `{"Process":{"ServiceName":"` + strings.Repeat("a", 1024) + `"}}`
+
+	tests := []struct {
+		name        string
+		body        []byte
+		encoding    string
+		contentType string
+		queue       int
+		want        int
+	}{
+		{"accepted", []byte(testPackage), "", "application/json", 1, http.StatusAccepted},
+		{"no content type", []byte(testPackage), "", "", 1, http.StatusAccepted},
+		{"gzip", gzipBytes(t, []byte(testPackage)), "gzip", "application/json", 1, http.StatusAccepted},
+		{"malformed json", []byte(`{"Process":`), "", "application/json", 1, http.StatusBadRequest},
+		{"invalid gzip", []byte(testPackage), "gzip", "application/json", 1, http.StatusBadRequest},
+		{"too large", []byte(padded), "", "application/json", 1, http.StatusRequestEntityTooLarge},
+		{"too large after gunzip", gzipBytes(t, []byte(padded)), "gzip", "application/json", 1, http.StatusRequestEntityTooLarge},
+		{"unsupported encoding", []byte(testPackage), "br", "application/json", 1, http.StatusUnsupportedMediaType},
+		{"unsupported content type", []byte(testPackage), "", "application/x-protobuf", 1, http.StatusUnsupportedMediaType},
+		{"aggregator full", []byte(testPackage), "", "application/json", 0, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver, buffer, batchCh := newTestReceiver(t, tt.queue, 512)
+
+			req := httptest.NewRequest(http.MethodPost, PackagesPath, bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			switch tt.want {
+			case http.StatusAccepted:
+				pkg := <-batchCh
+				if pkg.Process.ServiceName != "shop" {
+					t.Fatalf("service = %q, want shop", pkg.Process.ServiceName)
+				}
+				if _, ok := testutil.TagValue(pkg.Process.Tags, "agent.host"); !ok {
+					t.Fatalf("package was not enriched: %v", pkg.Process.Tags)
+				}
+			case http.StatusTooManyRequests:
+				if got := rec.Header().Get("Retry-After"); got != "1" {
+					t.Fatalf("Retry-After = %q, want 1", got)
+				}
+				if stats := buffer.Stats(); stats.Dropped != 1 {
+					t.Fatalf("dropped = %d, want 1", stats.Dropped)
+				}
+			case http.StatusBadRequest:
+				if len(batchCh) != 0 {
+					t.Fatal("an invalid body reached the aggregator")
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPReceiverDecodeErrors(t *testing.T) {
+	receiver, buffer, _ := newTestReceiver(t, 1, 512)
+
+	req := httptest.NewRequest(http.MethodPost, PackagesPath, strings.NewReader("not json"))
+	req.RemoteAddr = "10.0.0.1:5000"
+	receiver.ServeHTTP(httptest.NewRecorder(), req)
+
+	stats := buffer.Stats()
+	if stats.Received != 1 || stats.DecodeErrors != 1 {
+		t.Fatalf("received = %d, decode errors = %d, want 1 and 1", stats.Received, stats.DecodeErrors)
+	}
+	if stats.ErrorsBySender["10.0.0.1"] != 1 {
+		t.Fatalf("errors by sender = %v, want one for 10.0.0.1", stats.ErrorsBySender)
+	}
+}
+
+func TestHTTPReceiverMethod(t *testing.T) {
+	receiver, _, _ := newTestReceiver(t, 1, 512)
+
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PackagesPath, nil))
+
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodPost {
+		t.Fatalf("status = %d, Allow = %q, want 405 and POST", rec.Code, rec.Header().Get("Allow"))
+	}
+}
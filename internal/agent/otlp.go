@@ -0,0 +1,379 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"log"
+	"net"
+	"net/http"
+	"time"
+	"tracer/pkg/config"
+	"tracer/pkg/model"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+// OTLPTracesPath is the OTLP/HTTP traces endpoint, served on the HTTP intake.
+const OTLPTracesPath = "/v1/traces"
+
+// unknownService is the service name OpenTelemetry uses when service.name is missing.
+const unknownService = "unknown_service"
+
+// OTLPReceiver accepts OpenTelemetry traces over OTLP/gRPC (TraceService/Export)
+// and converts them into packages for the aggregator.
+type OTLPReceiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+	server *grpc.Server
+	addr   string
+	buffer *Buffer
+}
+
+// NewOTLPReceiver creates a gRPC receiver listening on conf.OTLP.GRPCAddr that hands packages to buffer.
+func NewOTLPReceiver(conf *Config, buffer *Buffer) *OTLPReceiver {
+	r := &OTLPReceiver{
+		server: grpc.NewServer(),
+		addr:   conf.OTLP.GRPCAddr,
+		buffer: buffer,
+	}
+	coltracepb.RegisterTraceServiceServer(r.server, r)
+
+	return r
+}
+
+// ListenAndServe serves until Close is called.
+func (r *OTLPReceiver) ListenAndServe() error {
+	lis, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		return err
+	}
+
+	log.Println("agent OTLP/gRPC intake listening on", r.addr)
+	return r.server.Serve(lis)
+}
+
+// Close stops the server.
+func (r *OTLPReceiver) Close() {
+	r.server.Stop()
+}
+
+// Export implements the OTLP TraceService.
+// When the aggregator is full it returns ResourceExhausted, which OpenTelemetry exporters retry.
+func (r *OTLPReceiver) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	r.buffer.recordReceived()
+
+	resp, accepted := offerOTLP(r.buffer, req)
+	if !accepted {
+		return nil, status.Error(codes.ResourceExhausted, "aggregator is full, retry later")
+	}
+
+	return resp, nil
+}
+
+// handleOTLP serves OTLP/HTTP with protobuf or JSON bodies.
+func (r *HTTPReceiver) handleOTLP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mediaType := contentType(req)
+	if mediaType != "application/x-protobuf" && mediaType != "application/json" {
+		http.Error(w, fmt.Sprintf("unsupported content type %q, want application/x-protobuf or application/json", mediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, code, err := r.readBody(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	exportReq := new(coltracepb.ExportTraceServiceRequest)
+	if mediaType == "application/json" {
+		err = unmarshalOTLPJSON(body, exportReq)
+	} else {
+		err = proto.Unmarshal(body, exportReq)
+	}
+	if err != nil {
+		r.buffer.recordDecodeError(senderIP(req))
+		http.Error(w, "invalid OTLP request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, accepted := offerOTLP(r.buffer, exportReq)
+	if !accepted {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "aggregator is full, retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	var out []byte
+	if mediaType == "application/json" {
+		out, err = protojson.Marshal(resp)
+	} else {
+		out, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	_, _ = w.Write(out)
+}
+
+// offerOTLP converts the request and offers every package to the aggregator.
+// accepted is false when nothing could be queued; packages that did not fit after
+// some were queued are reported as rejected spans in a partial success.
+func offerOTLP(buffer *Buffer, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, bool) {
+	queued, rejected := buffer.OfferAll(OTLPToPackages(req))
+
+	resp := new(coltracepb.ExportTraceServiceResponse)
+	if rejected == 0 {
+		return resp, true
+	}
+	if queued == 0 {
+		return nil, false
+	}
+
+	resp.PartialSuccess = &coltracepb.ExportTracePartialSuccess{
+		RejectedSpans: rejected,
+		ErrorMessage:  "aggregator is full",
+	}
+	return resp, true
+}
+
+// OTLPToPackages converts OTLP ResourceSpans into one package per resource.
+// Resource attributes become process tags, events become logs, links become LINK references,
+// and the span kind and status are kept as span.kind, error and otel.status_* tags.
+func OTLPToPackages(req *coltracepb.ExportTraceServiceRequest) []model.Package {
+	pkgs := make([]model.Package, 0, len(req.GetResourceSpans()))
+	for _, rs := range req.GetResourceSpans() {
+		process := model.Process{ServiceName: unknownService}
+		for _, kv := range rs.GetResource().GetAttributes() {
+			if kv.GetKey() == semconv.ServiceNameKey {
+				// 空的 service.name（或不是字符串的值）和缺省一样，用 unknown_service
+				if name := kv.GetValue().GetStringValue(); name != "" {
+					process.ServiceName = name
+				}
+				continue
+			}
+			process.Tags = append(process.Tags, config.Tag{Key: kv.GetKey(), Value: anyValue(kv.GetValue())})
+		}
+
+		pkg := model.Package{Process: process}
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				pkg.Spans = append(pkg.Spans, otlpSpan(s, ss.GetScope()))
+			}
+		}
+		if len(pkg.Spans) != 0 {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	return pkgs
+}
+
+func otlpSpan(s *tracepb.Span, scope *commonpb.InstrumentationScope) span.ToModel {
+	traceID := hex.EncodeToString(s.GetTraceId())
+	parentID := hex.EncodeToString(s.GetParentSpanId())
+
+	m := span.ToModel{
+		Operation: s.GetName(),
+		Context: span.SpanContext{
+			TraceID:  traceID,
+			SpanID:   hex.EncodeToString(s.GetSpanId()),
+			ParentID: parentID,
+			Sampled:  true,
+		},
+		StartTime: time.Unix(0, int64(s.GetStartTimeUnixNano())),
+		Tags:      attributesToTags(s.GetAttributes()),
+	}
+	// 结束时间早于开始时间的 span 记为 0，不存负数
+	if end, start := s.GetEndTimeUnixNano(), s.GetStartTimeUnixNano(); end > start {
+		m.Duration = time.Duration(end - start)
+	}
+
+	if kind := otlpSpanKind(s.GetKind()); kind != "" {
+		m.Tags = append(m.Tags, semconv.SpanKind(kind))
+	}
+	switch s.GetStatus().GetCode() {
+	case tracepb.Status_STATUS_CODE_ERROR:
+		m.Tags = append(m.Tags, semconv.Error(true), config.Tag{Key: semconv.StatusCodeKey, Value: semconv.StatusCodeError})
+	case tracepb.Status_STATUS_CODE_OK:
+		m.Tags = append(m.Tags, config.Tag{Key: semconv.StatusCodeKey, Value: semconv.StatusCodeOK})
+	}
+	if message := s.GetStatus().GetMessage(); message != "" {
+		m.Tags = append(m.Tags, config.Tag{Key: semconv.StatusDescriptionKey, Value: message})
+	}
+	if scope.GetName() != "" {
+		m.Tags = append(m.Tags, config.Tag{Key: semconv.ScopeNameKey, Value: scope.GetName()})
+	}
+	if scope.GetVersion() != "" {
+		m.Tags = append(m.Tags, config.Tag{Key: semconv.ScopeVersionKey, Value: scope.GetVersion()})
+	}
+
+	if parentID != "" {
+		m.References = append(m.References, span.Reference{TraceID: traceID, SpanID: parentID, RefType: span.ChildOf})
+	}
+	for _, link := range s.GetLinks() {
+		m.References = append(m.References, span.Reference{
+			TraceID:    hex.EncodeToString(link.GetTraceId()),
+			SpanID:     hex.EncodeToString(link.GetSpanId()),
+			RefType:    span.Link,
+			Attributes: attributesToTags(link.GetAttributes()),
+		})
+	}
+
+	for _, event := range s.GetEvents() {
+		m.Logs = append(m.Logs, span.Log{
+			Timestamp: time.Unix(0, int64(event.GetTimeUnixNano())),
+			Fields:    append([]config.Tag{semconv.Event(event.GetName())}, attributesToTags(event.GetAttributes())...),
+		})
+	}
+
+	return m
+}
+
+func otlpSpanKind(kind tracepb.Span_SpanKind) string {
+	switch kind {
+	case tracepb.Span_SPAN_KIND_SERVER:
+		return semconv.SpanKindServer
+	case tracepb.Span_SPAN_KIND_CLIENT:
+		return semconv.SpanKindClient
+	case tracepb.Span_SPAN_KIND_PRODUCER:
+		return semconv.SpanKindProducer
+	case tracepb.Span_SPAN_KIND_CONSUMER:
+		return semconv.SpanKindConsumer
+	case tracepb.Span_SPAN_KIND_INTERNAL:
+		return semconv.SpanKindInternal
+	default:
+		return ""
+	}
+}
+
+func attributesToTags(attrs []*commonpb.KeyValue) []config.Tag {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	tags := make([]config.Tag, 0, len(attrs))
+	for _, kv := range attrs {
+		tags = append(tags, config.Tag{Key: kv.GetKey(), Value: anyValue(kv.GetValue())})
+	}
+
+	return tags
+}
+
+// anyValue keeps scalar types; arrays and maps are stored as JSON strings.
+func anyValue(v *commonpb.AnyValue) interface{} {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return value.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return value.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(value.BytesValue)
+	case *commonpb.AnyValue_ArrayValue, *commonpb.AnyValue_KvlistValue:
+		data, err := json.Marshal(anyToJSON(v))
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	default:
+		return ""
+	}
+}
+
+func anyToJSON(v *commonpb.AnyValue) interface{} {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_ArrayValue:
+		out := make([]interface{}, 0, len(value.ArrayValue.GetValues()))
+		for _, item := range value.ArrayValue.GetValues() {
+			out = append(out, anyToJSON(item))
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		out := make(map[string]interface{}, len(value.KvlistValue.GetValues()))
+		for _, kv := range value.KvlistValue.GetValues() {
+			out[kv.GetKey()] = anyToJSON(kv.GetValue())
+		}
+		return out
+	default:
+		return anyValue(v)
+	}
+}
+
+// otlpIDFields are the JSON fields that OTLP/JSON encodes as hex instead of the protobuf JSON base64.
+var otlpIDFields = []string{"traceId", "trace_id", "spanId", "span_id", "parentSpanId", "parent_span_id"}
+
+// unmarshalOTLPJSON decodes OTLP/JSON, whose trace and span IDs are hex strings.
+// Numbers are kept as json.Number, so nanosecond timestamps and int64 attributes
+// written as plain numbers reach protojson without losing precision.
+func unmarshalOTLPJSON(body []byte, req *coltracepb.ExportTraceServiceRequest) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+
+	if err := hexIDsToBase64(doc); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, req)
+}
+
+// hexIDsToBase64 rewrites every ID field in the document, at any depth (spans and links).
+func hexIDsToBase64(node interface{}) error {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for _, field := range otlpIDFields {
+			if s, ok := n[field].(string); ok && s != "" {
+				raw, err := hex.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("%s is not hex: %q", field, s)
+				}
+				n[field] = base64.StdEncoding.EncodeToString(raw)
+			}
+		}
+		for _, child := range n {
+			if err := hexIDsToBase64(child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range n {
+			if err := hexIDsToBase64(child); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package agent
+
+import (
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"testing"
+	"time"
+	"tracer/internal/testutil"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+// otlpJSONRequest wraps one OTLP/JSON span in a request for the shop service.
+func otlpJSONRequest(spanJSON string) string {
+	return `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"shop"}}]},` +
+		`"scopeSpans":[{"spans":[` + spanJSON + `]}]}]}`
+}
+
+// decodeOTLPJSON runs body through unmarshalOTLPJSON and OTLPToPackages and returns the only span.
+func decodeOTLPJSON(t *testing.T, body string) (span.ToModel, error) {
+	t.Helper()
+	req := new(coltracepb.ExportTraceServiceRequest)
+	if err := unmarshalOTLPJSON([]byte(body), req); err != nil {
+		return span.ToModel{}, err
+	}
+	pkgs := OTLPToPackages(req)
+	if len(pkgs) != 1 || len(pkgs[0].Spans) != 1 {
+		t.Fatalf("got %d packages, want one package with one span", len(pkgs))
+	}
+	return pkgs[0].Spans[0], nil
+}
+
+func TestUnmarshalOTLPJSON(t *testing.T) {
+	const (
+		traceID  = "5b8efff798038103d269b633813fc60c"
+		spanID   = "eee19b7ec3c1b174"
+		parentID = "eee19b7ec3c1b173"
+	)
+
+	tests := []struct {
+		name    string
+		span    string
+		wantErr bool
+		check   func(t *testing.T, m span.ToModel)
+	}{
+		{
+			name: "hex ids",
+			span: `{"traceId":"` + traceID + `","spanId":"` + spanID + `","parentSpanId":"` + parentID + `","name":"op",` +
+				`"links":[{"traceId":"` + traceID + `","spanId":"` + parentID + `"}]}`,
+			check: func(t *testing.T, m span.ToModel) {
+				if m.Context.TraceID != traceID || m.Context.SpanID != spanID || m.Context.ParentID != parentID {
+					t.Fatalf("context = %+v, want %s/%s/%s", m.Context, traceID, spanID, parentID)
+				}
+				if len(m.References) != 2 || m.References[1].RefType != span.Link || m.References[1].SpanID != parentID {
+					t.Fatalf("references = %+v, want the parent and a link to %s", m.References, parentID)
+				}
+			},
+		},
+		{
+			name: "empty parent id",
+			span: `{"traceId":"` + traceID + `","spanId":"` + spanID + `","parentSpanId":"","name":"root"}`,
+			check: func(t *testing.T, m span.ToModel) {
+				if m.Context.ParentID != "" || len(m.References) != 0 {
+					t.Fatalf("root span has parent %q and references %+v", m.Context.ParentID, m.References)
+				}
+			},
+		},
+		{
+			name:    "base64 id",
+			span:    `{"traceId":"W47/95gDgQPSabYzgT/GDA==","spanId":"` + spanID + `","name":"op"}`,
+			wantErr: true,
+		},
+		{
+			name:    "odd length id",
+			span:    `{"traceId":"abc","spanId":"` + spanID + `","name":"op"}`,
+			wantErr: true,
+		},
+		{
+			name: "int64 attributes",
+			span: `{"traceId":"` + traceID + `","spanId":"` + spanID + `","name":"op","attributes":[` +
+				`{"key":"as_string","value":{"intValue":"9007199254740993"}},` +
+				`{"key":"as_number","value":{"intValue":9007199254740993}}]}`,
+			check: func(t *testing.T, m span.ToModel) {
+				for _, key := range []string{"as_string", "as_number"} {
+					if got, _ := testutil.TagValue(m.Tags, key); got != int64(9007199254740993) {
+						t.Errorf("%s = %#v, want int64(9007199254740993)", key, got)
+					}
+				}
+			},
+		},
+		{
+			name: "nanosecond timestamps",
+			span: `{"traceId":"` + traceID + `","spanId":"` + spanID + `","name":"op",` +
+				`"startTimeUnixNano":"1700000000000000001","endTimeUnixNano":1700000000250000001}`,
+			check: func(t *testing.T, m span.ToModel) {
+				if !m.StartTime.Equal(time.Unix(0, 1700000000000000001)) || m.Duration != 250*time.Millisecond {
+					t.Fatalf("start = %v, duration = %v, want the exact nanoseconds and 250ms", m.StartTime.UnixNano(), m.Duration)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := decodeOTLPJSON(t, otlpJSONRequest(tt.span))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, m)
+			}
+		})
+	}
+}
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func TestOTLPToPackages(t *testing.T) {
+	start := uint64(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	newSpan := func(end uint64) *tracepb.Span {
+		return &tracepb.Span{
+			TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanId:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			Name:              "op",
+			StartTimeUnixNano: start,
+			EndTimeUnixNano:   end,
+		}
+	}
+	request := func(attrs []*commonpb.KeyValue, spans ...*tracepb.Span) *coltracepb.ExportTraceServiceRequest {
+		return &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource:   &resourcepb.Resource{Attributes: attrs},
+			ScopeSpans: []*tracepb.ScopeSpans{{Spans: spans}},
+		}}}
+	}
+
+	tests := []struct {
+		name         string
+		req          *coltracepb.ExportTraceServiceRequest
+		wantPackages int
+		wantService  string
+		wantDuration time.Duration
+	}{
+		{
+			name:         "resource attributes",
+			req:          request([]*commonpb.KeyValue{stringAttr(semconv.ServiceNameKey, "shop"), stringAttr("host.name", "web-1")}, newSpan(start+uint64(time.Second))),
+			wantPackages: 1,
+			wantService:  "shop",
+			wantDuration: time.Second,
+		},
+		{
+			name:         "no service name",
+			req:          request(nil, newSpan(start+uint64(time.Millisecond))),
+			wantPackages: 1,
+			wantService:  unknownService,
+			wantDuration: time.Millisecond,
+		},
+		{
+			name:         "empty service name",
+			req:          request([]*commonpb.KeyValue{stringAttr(semconv.ServiceNameKey, "")}, newSpan(start+uint64(time.Millisecond))),
+			wantPackages: 1,
+			wantService:  unknownService,
+			wantDuration: time.Millisecond,
+		},
+		{
+			name:         "end before start",
+			req:          request([]*commonpb.KeyValue{stringAttr(semconv.ServiceNameKey, "shop")}, newSpan(start-uint64(time.Second))),
+			wantPackages: 1,
+			wantService:  "shop",
+		},
+		{
+			name: "no spans",
+			req:  request([]*commonpb.KeyValue{stringAttr(semconv.ServiceNameKey, "shop")}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkgs := OTLPToPackages(tt.req)
+			if len(pkgs) != tt.wantPackages {
+				t.Fatalf("%d packages, want %d", len(pkgs), tt.wantPackages)
+			}
+			if tt.wantPackages == 0 {
+				return
+			}
+
+			pkg := pkgs[0]
+			if pkg.Process.ServiceName != tt.wantService {
+				t.Fatalf("service = %q, want %q", pkg.Process.ServiceName, tt.wantService)
+			}
+			if _, ok := testutil.TagValue(pkg.Process.Tags, semconv.ServiceNameKey); ok {
+				t.Fatalf("%s was kept as a process tag: %v", semconv.ServiceNameKey, pkg.Process.Tags)
+			}
+			if m := pkg.Spans[0]; m.Duration != tt.wantDuration {
+				t.Fatalf("duration = %v, want %v", m.Duration, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestOTLPToPackagesTags(t *testing.T) {
+	req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			stringAttr(semconv.ServiceNameKey, "shop"),
+			stringAttr("host.name", "web-1"),
+		}},
+		ScopeSpans: []*tracepb.ScopeSpans{{
+			Scope: &commonpb.InstrumentationScope{Name: "net/http"},
+			Spans: []*tracepb.Span{{
+				TraceId: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+				SpanId:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+				Name:    "op",
+				Kind:    tracepb.Span_SPAN_KIND_CLIENT,
+				Status:  &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: "boom"},
+			}},
+		}},
+	}}}
+	pkg := OTLPToPackages(req)[0]
+
+	if got, _ := testutil.TagValue(pkg.Process.Tags, "host.name"); got != "web-1" {
+		t.Fatalf("process tag host.name = %v, want web-1", got)
+	}
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{semconv.SpanKindKey, semconv.SpanKindClient},
+		{semconv.ErrorKey, true},
+		{semconv.StatusCodeKey, semconv.StatusCodeError},
+		{semconv.StatusDescriptionKey, "boom"},
+		{semconv.ScopeNameKey, "net/http"},
+	}
+	for _, tt := range tests {
+		if got, _ := testutil.TagValue(pkg.Spans[0].Tags, tt.key); got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.key, got, tt.want)
+		}
+	}
+}
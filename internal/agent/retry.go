@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand/v2"
+	"time"
+)
+
+// errBreakerOpen is returned instead of calling a collector whose circuit breaker is open.
+var errBreakerOpen = errors.New("exporter: circuit breaker open")
+
+// errNoCollector is returned when the balancer knows no collector address.
+var errNoCollector = errors.New("exporter: no collector available")
+
+// isRetryable reports whether the same batch may succeed if sent again later.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTemporary reports whether a failed batch should be kept for later:
+// the collector was unavailable, overloaded or not called at all.
+// Any other error is a rejection that would fail again.
+func isTemporary(err error) bool {
+	return isRetryable(err) || errors.Is(err, errBreakerOpen) || errors.Is(err, errNoCollector)
+}
+
+// isCollectorFailure reports whether err says the collector is unhealthy, as opposed to rejecting the batch.
+// Only transport errors (surfaced as Unavailable) and overload count; a rejected batch says nothing
+// about the collector and must not open the breaker or eject it.
+func isCollectorFailure(err error) bool {
+	return isRetryable(err)
+}
+
+// serverRetryDelay returns the delay the collector asked for in a RetryInfo detail, or 0.
+func serverRetryDelay(err error) time.Duration {
+	s, ok := status.FromError(err)
+	if !ok {
+		return 0
+	}
+
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration()
+		}
+	}
+
+	return 0
+}
+
+// backoff computes the delays between retries: exponential, capped and jittered.
+type backoff struct {
+	conf     RetryConfig
+	interval time.Duration
+}
+
+func newBackoff(conf RetryConfig) *backoff {
+	return &backoff{conf: conf, interval: conf.InitialInterval}
+}
+
+// next returns the next delay, spread by ±Jitter around the current interval.
+func (b *backoff) next() time.Duration {
+	delay := b.interval
+	if b.conf.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + b.conf.Jitter*(2*rand.Float64()-1)))
+	}
+
+	b.interval = time.Duration(float64(b.interval) * b.conf.Multiplier)
+	if b.interval > b.conf.MaxInterval {
+		b.interval = b.conf.MaxInterval
+	}
+
+	return delay
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name string
+		conf RetryConfig
+		want []time.Duration
+	}{
+		{
+			name: "exponential up to the cap",
+			conf: RetryConfig{InitialInterval: 100 * time.Millisecond, MaxInterval: 500 * time.Millisecond, Multiplier: 2},
+			want: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name: "multiplier 1 is constant",
+			conf: RetryConfig{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 1},
+			want: []time.Duration{time.Second, time.Second, time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(tt.conf)
+			for i, want := range tt.want {
+				if got := b.next(); got != want {
+					t.Fatalf("delay %d = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	conf := RetryConfig{InitialInterval: time.Second, MaxInterval: time.Second, Multiplier: 2, Jitter: 0.2}
+	b := newBackoff(conf)
+	for i := 0; i < 100; i++ {
+		if got := b.next(); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("delay = %v, want within ±20%% of 1s", got)
+		}
+	}
+}
+
+func withRetryInfo(t *testing.T, code codes.Code, delay time.Duration) error {
+	t.Helper()
+	s, err := status.New(code, "busy").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Err()
+}
+
+func TestServerRetryDelay(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"retry info", withRetryInfo(t, codes.ResourceExhausted, 3*time.Second), 3 * time.Second},
+		{"no details", status.Error(codes.Unavailable, "down"), 0},
+		{"other detail", func() error {
+			s, _ := status.New(codes.Unavailable, "down").WithDetails(&errdetails.ErrorInfo{Reason: "x"})
+			return s.Err()
+		}(), 0},
+		{"not a status", errors.New("boom"), 0},
+		{"nil", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serverRetryDelay(tt.err); got != tt.want {
+				t.Fatalf("serverRetryDelay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryClassification(t *testing.T) {
+	tests := []struct {
+		err           error
+		wantRetryable bool
+		wantTemporary bool
+	}{
+		{status.Error(codes.Unavailable, ""), true, true},
+		{status.Error(codes.ResourceExhausted, ""), true, true},
+		{status.Error(codes.DeadlineExceeded, ""), true, true},
+		{status.Error(codes.InvalidArgument, ""), false, false},
+		{errBreakerOpen, false, true},
+		{fmt.Errorf("send: %w", errNoCollector), false, true},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.wantRetryable {
+			t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.wantRetryable)
+		}
+		if got := isTemporary(tt.err); got != tt.wantTemporary {
+			t.Errorf("isTemporary(%v) = %v, want %v", tt.err, got, tt.wantTemporary)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"tracer/pkg/config"
+	"tracer/pkg/model"
+	"tracer/pkg/resource"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+// ZipkinSpansPath is the Zipkin v2 span intake, served on the HTTP intake.
+const ZipkinSpansPath = "/api/v2/spans"
+
+// zipkinSpan is one span of a Zipkin v2 JSON list. Timestamps and durations are in microseconds.
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      int64              `json:"timestamp"`
+	Duration       int64              `json:"duration"`
+	Debug          bool               `json:"debug"`
+	Shared         bool               `json:"shared"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint"`
+	Annotations    []zipkinAnnotation `json:"annotations"`
+	Tags           map[string]string  `json:"tags"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// handleZipkin accepts a Zipkin v2 JSON list and answers 202 like a Zipkin server.
+// Spans without traceId or id are dropped; the response body says how many spans were dropped,
+// and a list with no valid span at all is answered with 400.
+func (r *HTTPReceiver) handleZipkin(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if mediaType := contentType(req); mediaType != "application/json" {
+		http.Error(w, fmt.Sprintf("unsupported content type %q, want application/json", mediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, code, err := r.readBody(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	var spans []zipkinSpan
+	if err := json.Unmarshal(body, &spans); err != nil {
+		r.buffer.recordDecodeError(senderIP(req))
+		http.Error(w, "invalid Zipkin spans: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pkgs, invalid := zipkinToPackages(spans, time.Now())
+	if invalid != 0 && len(pkgs) == 0 {
+		r.buffer.recordDecodeError(senderIP(req))
+		http.Error(w, fmt.Sprintf("invalid Zipkin spans: all %d spans lack traceId or id", invalid), http.StatusBadRequest)
+		return
+	}
+
+	queued, dropped := r.buffer.OfferAll(pkgs)
+	if queued == 0 && len(pkgs) != 0 {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "aggregator is full, retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if invalid != 0 || dropped != 0 {
+		_, _ = fmt.Fprintf(w, "dropped %d spans without traceId or id, %d spans because the aggregator is full\n", invalid, dropped)
+	}
+}
+
+// zipkinToPackages groups Zipkin spans into one package per local endpoint, and counts the spans
+// dropped for lacking an ID. Spans without a timestamp start at now, the time they were received.
+// Trace and span IDs are kept as sent, lower-cased, the same way the B3 propagator reads them,
+// so spans reported by Zipkin clients join the native spans of the same trace.
+// The server side of a shared span gets its own ID; children of it in the same list, reported
+// by the same endpoint, are moved under that ID. This only works within one POST: Brave and other
+// reporters flush their spans independently, so a child sent in a later list than its server span
+// stays under the client span.
+func zipkinToPackages(spans []zipkinSpan, now time.Time) (pkgs []model.Package, invalid int) {
+	// service name + IP -> index in pkgs
+	index := make(map[string]int)
+	// trace ID + span ID of a shared server span -> its endpoint key
+	shared := make(map[string]string)
+	for _, zs := range spans {
+		if zs.Shared && zs.TraceID != "" && zs.ID != "" {
+			shared[zipkinIDKey(zs.TraceID, zs.ID)] = zs.endpointKey()
+		}
+	}
+
+	for _, zs := range spans {
+		if zs.TraceID == "" || zs.ID == "" {
+			invalid++
+			continue
+		}
+
+		key := zs.endpointKey()
+		// A child of the server side still points at the shared ID. The client reports from
+		// another endpoint, so only children from the server's endpoint are moved.
+		if server, ok := shared[zipkinIDKey(zs.TraceID, zs.ParentID)]; ok && !zs.Shared && server == key {
+			zs.ParentID = sharedSpanID(strings.ToLower(zs.TraceID), strings.ToLower(zs.ParentID))
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(pkgs)
+			index[key] = i
+			pkgs = append(pkgs, model.Package{Process: zipkinProcess(zs.LocalEndpoint)})
+		}
+		pkgs[i].Spans = append(pkgs[i].Spans, zipkinToSpan(zs, now))
+	}
+
+	return pkgs, invalid
+}
+
+func zipkinProcess(endpoint *zipkinEndpoint) model.Process {
+	process := model.Process{ServiceName: unknownService}
+	if endpoint == nil {
+		return process
+	}
+
+	if endpoint.ServiceName != "" {
+		process.ServiceName = endpoint.ServiceName
+	}
+	if ip := endpoint.ip(); ip != "" {
+		process.Tags = append(process.Tags, config.Tag{Key: resource.IPTagKey, Value: ip})
+	}
+
+	return process
+}
+
+func zipkinToSpan(zs zipkinSpan, now time.Time) span.ToModel {
+	traceID := strings.ToLower(zs.TraceID)
+	spanID := strings.ToLower(zs.ID)
+	parentID := strings.ToLower(zs.ParentID)
+	if zs.Shared {
+		// B3 single-span RPC: client and server share one ID. The server side gets a new ID
+		// under the client span.
+		parentID = spanID
+		spanID = sharedSpanID(traceID, spanID)
+	}
+
+	startTime := now
+	if zs.Timestamp != 0 {
+		startTime = time.UnixMicro(zs.Timestamp)
+	}
+
+	m := span.ToModel{
+		Operation: zs.Name,
+		Context: span.SpanContext{
+			TraceID:  traceID,
+			SpanID:   spanID,
+			ParentID: parentID,
+			Sampled:  true,
+			Debug:    zs.Debug,
+		},
+		StartTime: startTime,
+		Duration:  time.Duration(zs.Duration) * time.Microsecond,
+	}
+
+	if kind := strings.ToLower(zs.Kind); kind != "" {
+		m.Tags = append(m.Tags, semconv.SpanKind(kind))
+	}
+	keys := make([]string, 0, len(zs.Tags))
+	for key := range zs.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := zs.Tags[key]
+		// The Zipkin error tag holds the error message.
+		if key == semconv.ErrorKey {
+			m.Tags = append(m.Tags, semconv.Error(true))
+			if value != "" && value != "true" {
+				m.Tags = append(m.Tags, semconv.ExceptionMessage(value))
+			}
+			continue
+		}
+		m.Tags = append(m.Tags, config.Tag{Key: key, Value: value})
+	}
+	if zs.LocalEndpoint != nil && zs.LocalEndpoint.Port != 0 {
+		m.Tags = append(m.Tags, semconv.NetHostPort(zs.LocalEndpoint.Port))
+	}
+	if remote := zs.RemoteEndpoint; remote != nil {
+		if remote.ServiceName != "" {
+			m.Tags = append(m.Tags, semconv.PeerService(remote.ServiceName))
+		}
+		if ip := remote.ip(); ip != "" {
+			m.Tags = append(m.Tags, semconv.NetPeerIP(ip))
+		}
+		if remote.Port != 0 {
+			m.Tags = append(m.Tags, semconv.NetPeerPort(remote.Port))
+		}
+	}
+
+	if parentID != "" {
+		m.References = append(m.References, span.Reference{TraceID: traceID, SpanID: parentID, RefType: span.ChildOf})
+	}
+
+	for _, annotation := range zs.Annotations {
+		ts := startTime
+		if annotation.Timestamp != 0 {
+			ts = time.UnixMicro(annotation.Timestamp)
+		}
+		m.Logs = append(m.Logs, span.Log{
+			Timestamp: ts,
+			Fields:    []config.Tag{semconv.Event(annotation.Value)},
+		})
+	}
+
+	return m
+}
+
+// sharedSpanID derives the ID of the server side of a shared span. It is a hash rather than
+// a random ID, so a client that sends the same span twice gets the same ID twice.
+func sharedSpanID(traceID, spanID string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(traceID))
+	_, _ = h.Write([]byte(spanID))
+	_, _ = h.Write([]byte("shared"))
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// zipkinIDKey identifies a span regardless of the case of its hex IDs.
+func zipkinIDKey(traceID, spanID string) string {
+	return strings.ToLower(traceID) + "|" + strings.ToLower(spanID)
+}
+
+// endpointKey is the service name and IP of the local endpoint, which picks the package of the span.
+func (zs *zipkinSpan) endpointKey() string {
+	if e := zs.LocalEndpoint; e != nil {
+		return e.ServiceName + "|" + e.ip()
+	}
+	return unknownService
+}
+
+// ip prefers IPv4, like Zipkin's own UI.
+func (e *zipkinEndpoint) ip() string {
+	if e.IPv4 != "" {
+		return e.IPv4
+	}
+	return e.IPv6
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tracer/pkg/model"
+	"tracer/pkg/span"
+)
+
+const zipkinTraceID = "463ac35c9f6413ad48485a3953bb6124"
+
+func zipkinEndpointFor(service, ip string) *zipkinEndpoint {
+	return &zipkinEndpoint{ServiceName: service, IPv4: ip}
+}
+
+// findSpan returns the span of service with the given operation.
+func findSpan(t *testing.T, pkgs []model.Package, service, operation string) span.ToModel {
+	t.Helper()
+	for _, pkg := range pkgs {
+		if pkg.Process.ServiceName != service {
+			continue
+		}
+		for _, s := range pkg.Spans {
+			if s.Operation == operation {
+				return s
+			}
+		}
+	}
+	t.Fatalf("no %s span from %s", operation, service)
+	return span.ToModel{}
+}
+
+func TestZipkinToPackagesSharedSpan(t *testing.T) {
+	frontend := zipkinEndpointFor("frontend", "10.0.0.1")
+	backend := zipkinEndpointFor("backend", "10.0.0.2")
+	spans := []zipkinSpan{
+		{TraceID: zipkinTraceID, ID: "0000000000000001", Name: "root", LocalEndpoint: frontend, Timestamp: 1},
+		{TraceID: zipkinTraceID, ID: "0000000000000002", ParentID: "0000000000000001", Name: "call", Kind: "CLIENT", LocalEndpoint: frontend, Timestamp: 1},
+		{TraceID: zipkinTraceID, ID: "0000000000000002", ParentID: "0000000000000001", Name: "handle", Kind: "SERVER", Shared: true, LocalEndpoint: backend, Timestamp: 1},
+		{TraceID: strings.ToUpper(zipkinTraceID), ID: "0000000000000003", ParentID: "0000000000000002", Name: "query", LocalEndpoint: backend, Timestamp: 1},
+	}
+
+	pkgs, invalid := zipkinToPackages(spans, time.Now())
+	if invalid != 0 || len(pkgs) != 2 {
+		t.Fatalf("%d packages and %d invalid spans, want 2 and 0", len(pkgs), invalid)
+	}
+
+	client := findSpan(t, pkgs, "frontend", "call")
+	server := findSpan(t, pkgs, "backend", "handle")
+	child := findSpan(t, pkgs, "backend", "query")
+
+	if client.Context.SpanID != "0000000000000002" || client.Context.ParentID != "0000000000000001" {
+		t.Fatalf("client = %s parent %s, want the IDs as sent", client.Context.SpanID, client.Context.ParentID)
+	}
+	if server.Context.SpanID == client.Context.SpanID || server.Context.ParentID != client.Context.SpanID {
+		t.Fatalf("server = %s parent %s, want its own ID under the client %s",
+			server.Context.SpanID, server.Context.ParentID, client.Context.SpanID)
+	}
+	if child.Context.ParentID != server.Context.SpanID {
+		t.Fatalf("server child parent = %s, want the server span %s", child.Context.ParentID, server.Context.SpanID)
+	}
+	if len(child.References) != 1 || child.References[0].SpanID != server.Context.SpanID {
+		t.Fatalf("server child references = %+v, want CHILD_OF %s", child.References, server.Context.SpanID)
+	}
+
+	// 同一个 span 重复上报时服务端的 ID 不变
+	again, _ := zipkinToPackages(spans, time.Now())
+	if id := findSpan(t, again, "backend", "handle").Context.SpanID; id != server.Context.SpanID {
+		t.Fatalf("server ID = %s on the second report, want %s", id, server.Context.SpanID)
+	}
+}
+
+func TestZipkinToPackages(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	frontend := zipkinEndpointFor("frontend", "10.0.0.1")
+
+	tests := []struct {
+		name         string
+		spans        []zipkinSpan
+		wantPackages int
+		wantInvalid  int
+		wantStart    time.Time
+	}{
+		{
+			name:         "timestamp",
+			spans:        []zipkinSpan{{TraceID: zipkinTraceID, ID: "1", LocalEndpoint: frontend, Timestamp: 1700000000000001, Duration: 5}},
+			wantPackages: 1,
+			wantStart:    time.UnixMicro(1700000000000001),
+		},
+		{
+			name:         "missing timestamp",
+			spans:        []zipkinSpan{{TraceID: zipkinTraceID, ID: "1", LocalEndpoint: frontend}},
+			wantPackages: 1,
+			wantStart:    now,
+		},
+		{
+			name: "missing ids",
+			spans: []zipkinSpan{
+				{ID: "1", LocalEndpoint: frontend},
+				{TraceID: zipkinTraceID, LocalEndpoint: frontend},
+				{TraceID: zipkinTraceID, ID: "1", LocalEndpoint: frontend, Timestamp: 1700000000000001},
+			},
+			wantPackages: 1,
+			wantInvalid:  2,
+			wantStart:    time.UnixMicro(1700000000000001),
+		},
+		{
+			name:        "all invalid",
+			spans:       []zipkinSpan{{ID: "1"}, {TraceID: zipkinTraceID}},
+			wantInvalid: 2,
+		},
+		{
+			name: "one package per endpoint",
+			spans: []zipkinSpan{
+				{TraceID: zipkinTraceID, ID: "1", LocalEndpoint: frontend, Timestamp: 1700000000000001},
+				{TraceID: zipkinTraceID, ID: "2", LocalEndpoint: zipkinEndpointFor("frontend", "10.0.0.9"), Timestamp: 1700000000000001},
+				{TraceID: zipkinTraceID, ID: "3", Timestamp: 1700000000000001},
+			},
+			wantPackages: 3,
+			wantStart:    time.UnixMicro(1700000000000001),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkgs, invalid := zipkinToPackages(tt.spans, now)
+			if len(pkgs) != tt.wantPackages || invalid != tt.wantInvalid {
+				t.Fatalf("%d packages and %d invalid spans, want %d and %d", len(pkgs), invalid, tt.wantPackages, tt.wantInvalid)
+			}
+			if len(pkgs) != 0 && !pkgs[0].Spans[0].StartTime.Equal(tt.wantStart) {
+				t.Fatalf("start = %v, want %v", pkgs[0].Spans[0].StartTime, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestHandleZipkin(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		queue    int
+		want     int
+		wantBody string
+	}{
+		{"accepted", `[{"traceId":"1","id":"1"}]`, 1, http.StatusAccepted, ""},
+		{"some invalid", `[{"traceId":"1","id":"1"},{"id":"2"}]`, 1, http.StatusAccepted, "dropped 1 spans without traceId or id, 0 spans"},
+		{"all invalid", `[{"id":"1"},{"traceId":"1"}]`, 1, http.StatusBadRequest, "all 2 spans lack traceId or id"},
+		{"malformed json", `{"traceId":"1"}`, 1, http.StatusBadRequest, "invalid Zipkin spans"},
+		{"aggregator full", `[{"traceId":"1","id":"1"}]`, 0, http.StatusTooManyRequests, ""},
+		{"partly full", `[{"traceId":"1","id":"1","localEndpoint":{"serviceName":"a"}},{"traceId":"1","id":"2","localEndpoint":{"serviceName":"b"}}]`,
+			1, http.StatusAccepted, "0 spans without traceId or id, 1 spans because the aggregator is full"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver, _, _ := newTestReceiver(t, tt.queue, 1<<10)
+
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ZipkinSpansPath, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
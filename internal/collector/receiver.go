@@ -5,6 +5,8 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"log"
 	"net"
 	"sort"
@@ -41,6 +43,8 @@ func (r *Receiver) init(spanCh chan<- *model.FlatSpan) error {
 	// 2. 实例化 gRPC 服务端
 	r.server = grpc.NewServer()
 	pb.RegisterCollectorServiceServer(r.server, r)
+	// agent 通过标准的 gRPC health 服务判断 collector 是否可用
+	healthpb.RegisterHealthServer(r.server, health.NewServer())
 
 	r.lis = lis
 	r.spanChan = spanCh
@@ -62,11 +66,15 @@ func (r *Receiver) Run() {
 }
 
 // Export implements the gRPC Export method to receive trace data.
-// It validates the batch and pushes flattened spans to the span channel.
+// It validates the batch, drops the invalid spans and pushes the rest, flattened, to the span channel.
 func (r *Receiver) Export(ctx context.Context, batchPkg *pb.BatchPackage) (*pb.ExportResponse, error) {
-	if err := r.validator.Validate(batchPkg); err != nil {
+	dropped, err := r.validator.Validate(batchPkg)
+	if err != nil {
 		return nil, err
 	}
+	if dropped != 0 {
+		log.Printf("collector: dropped %d invalid spans", dropped)
+	}
 
 	spans := r.ConvertBatchToFlatSpans(batchPkg)
 
@@ -104,6 +112,7 @@ func (r *Receiver) ConvertBatchToFlatSpans(batchPkg *pb.BatchPackage) []*model.F
 				OperationName: s.GetOperation(),
 				Baggage:       convertTags(s.GetContext().GetBaggage()),
 				Sampled:       s.GetContext().GetSampled(),
+				Debug:         s.GetContext().GetDebug(),
 				StartTime:     s.GetStartTime().AsTime().UnixMicro(),
 				Duration:      s.GetDuration().AsDuration().Microseconds(),
 				Tags:          convertTags(s.GetTags()),
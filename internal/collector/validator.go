@@ -2,47 +2,123 @@ package collector
 
 import (
 	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
 	"time"
 	pb "tracer/internal/proto"
+	"tracer/pkg/span"
 )
 
+// EnvMaxSpanAge overrides DefaultMaxSpanAge, e.g. COLLECTOR_MAX_SPAN_AGE=720h; 0 turns the limit off.
+const EnvMaxSpanAge = "COLLECTOR_MAX_SPAN_AGE"
+
+// DefaultMaxSpanAge matches the ClickHouse TTL: older spans would be deleted as soon as they are stored.
+const DefaultMaxSpanAge = 7 * 24 * time.Hour
+
 // Validator validates the structure and content of trace packages.
 type Validator struct {
+	// MaxSpanAge rejects spans that started longer ago; 0 accepts any start time,
+	// e.g. to backfill spans from old log files.
+	MaxSpanAge time.Duration
 }
 
-// NewValidator creates a new Validator.
+// NewValidator creates a new Validator, reading the max span age from COLLECTOR_MAX_SPAN_AGE.
 func NewValidator() (*Validator, error) {
-	return &Validator{}, nil
+	v := &Validator{MaxSpanAge: DefaultMaxSpanAge}
+	if value, ok := os.LookupEnv(EnvMaxSpanAge); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s %q", EnvMaxSpanAge, value)
+		}
+		v.MaxSpanAge = d
+	}
+
+	return v, nil
 }
 
 // Validate checks the batch package for missing fields, invalid time, or excessive size.
-func (v *Validator) Validate(pkg *pb.BatchPackage) error {
-	for _, pkg := range pkg.GetPackages() {
+// Invalid spans are removed from the batch and counted in dropped; the rest of the batch is kept.
+// Only a batch with no valid span left is rejected, with InvalidArgument, so agents can tell it
+// apart from an unavailable collector. A bad reference is removed from its span.
+func (v *Validator) Validate(batch *pb.BatchPackage) (dropped int, err error) {
+	var (
+		kept   int
+		reason string
+	)
+	for _, pkg := range batch.GetPackages() {
 		// 1. Validate Process
 		if pkg.GetProcess().GetServiceName() == "" {
-			return fmt.Errorf("missing service name")
+			dropped += len(pkg.GetSpans())
+			reason = "missing service name"
+			pkg.Spans = nil
+			continue
 		}
 
 		// 2. Validate Spans
+		spans := pkg.GetSpans()[:0]
 		for _, s := range pkg.GetSpans() {
-			ctx := s.GetContext()
-			if ctx.GetTraceId() == "" || ctx.GetSpanId() == "" {
-				return fmt.Errorf("missing trace/span id")
+			if r := v.invalidSpan(s); r != "" {
+				dropped++
+				reason = r
+				continue
 			}
 
-			// 3. Time validity (check for future timestamps or too old)
-			startTime := s.GetStartTime().AsTime()
-			if time.Since(startTime) > 24*time.Hour {
-				return fmt.Errorf("span is too old")
-			}
+			// 5. References (links)
+			s.References = v.validReferences(s.GetReferences())
+			spans = append(spans, s)
+		}
+		pkg.Spans = spans
+		kept += len(spans)
+	}
 
-			// 4. Size limits (prevent large tags from clogging Kafka)
-			for k, v := range s.GetTags() {
-				if len(k) > 128 || len(v) > 2048 {
-					return fmt.Errorf("tag too large")
-				}
-			}
+	if kept == 0 && dropped != 0 {
+		return dropped, status.Error(codes.InvalidArgument, reason)
+	}
+
+	return dropped, nil
+}
+
+// invalidSpan returns why the span must be dropped, or "" if it is valid.
+func (v *Validator) invalidSpan(s *pb.SpanModel) string {
+	ctx := s.GetContext()
+	if ctx.GetTraceId() == "" || ctx.GetSpanId() == "" {
+		return "missing trace/span id"
+	}
+
+	// 3. Time validity (check for too old)
+	if v.MaxSpanAge > 0 && time.Since(s.GetStartTime().AsTime()) > v.MaxSpanAge {
+		return "span is too old"
+	}
+
+	// 4. Size limits (prevent large tags from clogging Kafka)
+	if !validAttributes(s.GetTags()) {
+		return "tag too large"
+	}
+
+	return ""
+}
+
+// validReferences drops the references that point nowhere or carry oversized attributes,
+// and maps legacy reference types onto the current ones.
+func (v *Validator) validReferences(refs []*pb.Reference) []*pb.Reference {
+	valid := refs[:0]
+	for _, ref := range refs {
+		if ref.GetTraceId() == "" || ref.GetSpanId() == "" || !validAttributes(ref.GetAttributes()) {
+			continue
+		}
+		ref.RefType = span.NormalizeRefType(ref.GetRefType())
+		valid = append(valid, ref)
+	}
+
+	return valid
+}
+
+func validAttributes(attributes map[string]string) bool {
+	for k, v := range attributes {
+		if len(k) > 128 || len(v) > 2048 {
+			return false
 		}
 	}
-	return nil
+	return true
 }
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+	pb "tracer/internal/proto"
+	"tracer/pkg/span"
+)
+
+func validSpan(references ...*pb.Reference) *pb.SpanModel {
+	return &pb.SpanModel{
+		Operation:  "op",
+		Context:    &pb.SpanContext{TraceId: "t", SpanId: "s"},
+		StartTime:  timestamppb.New(time.Now()),
+		References: references,
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	v, _ := NewValidator()
+
+	tests := []struct {
+		name string
+		ref  *pb.Reference
+		want []string
+	}{
+		{"kept", &pb.Reference{TraceId: "t", SpanId: "p", RefType: span.ChildOf}, []string{span.ChildOf}},
+		{"legacy follow from", &pb.Reference{TraceId: "t", SpanId: "p", RefType: "FOLLOW_FROm"}, []string{span.FollowFrom}},
+		{"missing span id", &pb.Reference{TraceId: "t", RefType: span.Link}, nil},
+		{"missing trace id", &pb.Reference{SpanId: "p", RefType: span.Link}, nil},
+		{"attribute too large", &pb.Reference{TraceId: "t", SpanId: "p", Attributes: map[string]string{"k": string(make([]byte, 4096))}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validSpan(tt.ref)
+			batch := &pb.BatchPackage{Packages: []*pb.Package{{
+				Process: &pb.Process{ServiceName: "svc"},
+				Spans:   []*pb.SpanModel{s},
+			}}}
+
+			if _, err := v.Validate(batch); err != nil {
+				t.Fatalf("Validate = %v, want the batch accepted", err)
+			}
+
+			var got []string
+			for _, ref := range s.GetReferences() {
+				got = append(got, ref.GetRefType())
+			}
+			if len(got) != len(tt.want) || (len(got) == 1 && got[0] != tt.want[0]) {
+				t.Fatalf("reference types = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDropsInvalidSpans(t *testing.T) {
+	old := validSpan()
+	old.StartTime = timestamppb.New(time.Now().Add(-30 * 24 * time.Hour))
+	noID := validSpan()
+	noID.Context.SpanId = ""
+	bigTag := validSpan()
+	bigTag.Tags = map[string]string{"k": string(make([]byte, 4096))}
+
+	tests := []struct {
+		name        string
+		maxSpanAge  time.Duration
+		spans       []*pb.SpanModel
+		wantKept    int
+		wantDropped int
+		wantErr     bool
+	}{
+		{"all valid", DefaultMaxSpanAge, []*pb.SpanModel{validSpan(), validSpan()}, 2, 0, false},
+		{"invalid spans dropped", DefaultMaxSpanAge, []*pb.SpanModel{validSpan(), old, noID, bigTag}, 1, 3, false},
+		{"no valid span rejects the batch", DefaultMaxSpanAge, []*pb.SpanModel{old, noID}, 0, 2, true},
+		{"no age limit keeps backfilled spans", 0, []*pb.SpanModel{validSpan(), old}, 2, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{MaxSpanAge: tt.maxSpanAge}
+			pkg := &pb.Package{Process: &pb.Process{ServiceName: "svc"}, Spans: append([]*pb.SpanModel(nil), tt.spans...)}
+
+			dropped, err := v.Validate(&pb.BatchPackage{Packages: []*pb.Package{pkg}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate error = %v, want error %v", err, tt.wantErr)
+			}
+			if dropped != tt.wantDropped || len(pkg.Spans) != tt.wantKept {
+				t.Fatalf("dropped %d and kept %d, want %d and %d", dropped, len(pkg.Spans), tt.wantDropped, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestValidateMissingServiceName(t *testing.T) {
+	v, _ := NewValidator()
+	batch := &pb.BatchPackage{Packages: []*pb.Package{
+		{Process: &pb.Process{}, Spans: []*pb.SpanModel{validSpan()}},
+		{Process: &pb.Process{ServiceName: "svc"}, Spans: []*pb.SpanModel{validSpan()}},
+	}}
+
+	dropped, err := v.Validate(batch)
+	if err != nil || dropped != 1 {
+		t.Fatalf("Validate = %d, %v, want 1 span dropped and the batch accepted", dropped, err)
+	}
+}
+
+func TestNewValidatorMaxSpanAge(t *testing.T) {
+	t.Setenv(EnvMaxSpanAge, "720h")
+	v, err := NewValidator()
+	if err != nil || v.MaxSpanAge != 720*time.Hour {
+		t.Fatalf("NewValidator = %+v, %v, want a max age of 720h", v, err)
+	}
+
+	t.Setenv(EnvMaxSpanAge, "soon")
+	if _, err := NewValidator(); err == nil {
+		t.Fatal("NewValidator accepted an invalid max age")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"time"
 	"tracer/pkg/model"
+	"tracer/pkg/semconv"
 )
 
 // Normalizer standardizes span data (e.g., service names, timestamps).
@@ -16,20 +17,79 @@ func NewNormalizer() *Normalizer {
 }
 
 // Normalize modifies the span in place to ensure consistency.
-// It lowercases service names, adds default tags, and standardizes timestamps.
+// It lowercases service names, maps legacy tag and log field keys onto the semconv ones,
+// adds default tags, and standardizes timestamps.
 func (n *Normalizer) Normalize(span *model.FlatSpan) {
 	// 1. Unify service names to lowercase for easier search
 	span.ServiceName = strings.ToLower(span.ServiceName)
 
-	// 2. Add default tags
+	// 2. Map legacy spellings (http.path, db.type, error.kind, ...) onto canonical keys
+	span.Tags = n.normalizeTags(span.Tags)
+	for _, log := range span.Logs {
+		if log != nil {
+			log.Fields = normalizeFields(log.Fields)
+		}
+	}
+
+	// 3. Add default tags
 	if span.Tags == nil {
 		span.Tags = make(map[string]interface{})
 	}
 	span.Tags["ingested_at"] = time.Now().Format(time.RFC3339)
 
-	// 3. Normalize timestamps to milliseconds if they appear to be in seconds
+	// 4. Normalize timestamps to milliseconds if they appear to be in seconds
 	// Assuming 1e12 as a threshold to distinguish seconds from milliseconds
 	if span.StartTime < 1e12 { // Likely in seconds
 		span.StartTime *= 1e3
 	}
 }
+
+// normalizeTags returns the tags with legacy keys renamed. A canonical key already present wins
+// over its legacy spelling. The input map is not modified.
+func (n *Normalizer) normalizeTags(tags map[string]interface{}) map[string]interface{} {
+	if tags == nil {
+		return nil
+	}
+
+	out := make(map[string]interface{}, len(tags))
+	for key, value := range tags {
+		if _, ok := semconv.CanonicalKey(key); !ok {
+			out[key] = value
+		}
+	}
+	for key, value := range tags {
+		if canonical, ok := semconv.CanonicalKey(key); ok {
+			if _, exists := out[canonical]; !exists {
+				out[canonical] = value
+			}
+		}
+	}
+
+	if kind, ok := out[semconv.SpanKindKey].(string); ok {
+		out[semconv.SpanKindKey] = semconv.CanonicalSpanKind(kind)
+	}
+
+	return out
+}
+
+func normalizeFields(fields map[string]string) map[string]string {
+	if fields == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(fields))
+	for key, value := range fields {
+		if _, ok := semconv.CanonicalKey(key); !ok {
+			out[key] = value
+		}
+	}
+	for key, value := range fields {
+		if canonical, ok := semconv.CanonicalKey(key); ok {
+			if _, exists := out[canonical]; !exists {
+				out[canonical] = value
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,62 @@
+package ingestor
+
+import (
+	"reflect"
+	"testing"
+	pb "tracer/internal/proto"
+	"tracer/pkg/model"
+)
+
+func TestNormalizeLegacyKeys(t *testing.T) {
+	tags := map[string]interface{}{
+		"http.path":   "/user/1",
+		"db.type":     "mysql",
+		"http.method": "GET",
+		// the canonical key wins over its legacy spelling
+		"http.verb": "POST",
+		"method":    "custom",
+		"span.kind": "RPC_CLIENT",
+	}
+	fields := map[string]string{
+		"error.kind":        "io.EOF",
+		"exception.message": "canonical",
+		"error.message":     "legacy",
+		"event":             "error",
+	}
+	span := &model.FlatSpan{
+		ServiceName: "Checkout",
+		StartTime:   1700000000000,
+		Tags:        tags,
+		Logs:        []*pb.Log{{Fields: fields}, nil},
+	}
+
+	NewNormalizer().Normalize(span)
+
+	wantTags := map[string]interface{}{
+		"http.target": "/user/1",
+		"db.system":   "mysql",
+		"http.method": "GET",
+		"method":      "custom",
+		"span.kind":   "client",
+	}
+	delete(span.Tags, "ingested_at")
+	if !reflect.DeepEqual(span.Tags, wantTags) {
+		t.Errorf("tags = %v, want %v", span.Tags, wantTags)
+	}
+
+	wantFields := map[string]string{
+		"exception.type":    "io.EOF",
+		"exception.message": "canonical",
+		"event":             "error",
+	}
+	if !reflect.DeepEqual(span.Logs[0].Fields, wantFields) {
+		t.Errorf("log fields = %v, want %v", span.Logs[0].Fields, wantFields)
+	}
+
+	if span.ServiceName != "checkout" {
+		t.Errorf("service name = %q, want checkout", span.ServiceName)
+	}
+	if _, ok := tags["http.path"]; !ok || len(tags) != 6 {
+		t.Errorf("the input tags were modified: %v", tags)
+	}
+}
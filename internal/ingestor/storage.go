@@ -142,6 +142,7 @@ func (s *Storage) WriteInDatabase(data []*model.StorageSpan) error {
             StatusCode, StatusMessage,
             Attributes,
             EventNames, EventTimesUs, EventAttrs,
+            Links.TraceID, Links.SpanID, Links.RefType, Links.Attributes,
             ProcessID, ResourceAttrs,
             TimestampUs
         )
@@ -155,15 +156,8 @@ func (s *Storage) WriteInDatabase(data []*model.StorageSpan) error {
 	for _, span := range data {
 		// 特殊处理：EventAttrs []map[string]string 转为 []string(JSON)
 		// 因为 ClickHouse 的 Array(Map) 性能较差且驱动支持复杂
-		eventAttrsStrs := make([]string, len(span.EventAttrs))
-		for i, attrMap := range span.EventAttrs {
-			if attrMap != nil {
-				data, _ := json.Marshal(attrMap)
-				eventAttrsStrs[i] = string(data)
-			} else {
-				eventAttrsStrs[i] = "{}"
-			}
-		}
+		eventAttrsStrs := attrsToJSON(span.EventAttrs)
+		linkAttrsStrs := attrsToJSON(span.LinkAttrs)
 
 		// 严格按照上面 INSERT 语句的顺序 Append
 		err := batch.Append(
@@ -181,6 +175,10 @@ func (s *Storage) WriteInDatabase(data []*model.StorageSpan) error {
 			span.EventNames,   // []string
 			span.EventTimesUs, // []int64
 			eventAttrsStrs,    // []string (JSON 序列化后的数组)
+			span.LinkTraceIDs, // Links Nested 列按子列拆成数组写入
+			span.LinkSpanIDs,
+			span.LinkTypes,
+			linkAttrsStrs,
 			span.ProcessID,
 			span.ResourceAttrs, // map[string]string
 			span.TimestampUs,
@@ -203,6 +201,20 @@ func (s *Storage) WriteInDatabase(data []*model.StorageSpan) error {
 	return nil
 }
 
+// attrsToJSON 把 []map[string]string 转为 JSON 字符串数组，空 map 写成 "{}"
+func attrsToJSON(attrs []map[string]string) []string {
+	out := make([]string, len(attrs))
+	for i, attrMap := range attrs {
+		if attrMap != nil {
+			data, _ := json.Marshal(attrMap)
+			out[i] = string(data)
+		} else {
+			out[i] = "{}"
+		}
+	}
+	return out
+}
+
 func (s *Storage) WriteInFile(data []*model.StorageSpan) {
 	if len(data) == 0 {
 		return
@@ -21,11 +21,17 @@ func NewTailSampler(sampleRate float64, slowThreshold time.Duration) *TailSample
 }
 
 // IsSampled checks if the trace (collection of spans) should be sampled.
-// It prioritizes traces with errors or slow requests.
+// Debug traces are always kept; after that it prioritizes traces with errors or slow requests.
 func (t *TailSampler) IsSampled(spans []*model.StorageSpan) bool {
 	hasError := false
 	isSlow := false
 
+	for _, span := range spans {
+		if span.Debug {
+			return true
+		}
+	}
+
 	for _, span := range spans {
 		// Check for errors
 		if span.StatusCode == "ERROR" {
@@ -0,0 +1,14 @@
+// Package testutil holds helpers shared by the tests of several packages.
+package testutil
+
+import "tracer/pkg/config"
+
+// TagValue returns the value of the first tag with key, and whether there was one.
+func TagValue(tags []config.Tag, key string) (interface{}, bool) {
+	for _, tag := range tags {
+		if tag.Key == key {
+			return tag.Value, true
+		}
+	}
+	return nil, false
+}
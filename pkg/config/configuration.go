@@ -1,7 +1,40 @@
 package config
 
+import "tracer/pkg/metrics"
+
 type Configuration struct {
+	// Disabled makes tracer.New return a NoopTracer. Nil means not set, so a lower-precedence
+	// source (env, file) can still decide; use Bool(false) to turn tracing on explicitly.
+	Disabled    *bool
 	ServiceName string
 	Sampler     *SamplerConfig
 	Reporter    *ReporterConfig
+	IDGenerator *IDGeneratorConfig
+	// Propagation lists the header formats used by Inject and Extract, e.g. "tracer" and "b3".
+	Propagation []string
+	// Tags are static process tags attached to every package sent to the agent.
+	Tags []Tag
+	// Resource controls which process/host detectors add tags next to Tags.
+	Resource *ResourceConfig
+	// PprofLabels makes StartSpanFromContext label the goroutine with span_id and operation,
+	// so CPU profiles can be filtered per span. Labels are restored when the span finishes.
+	// Nil means not set, like Disabled.
+	PprofLabels *bool
+	// Metrics receives the SDK self-telemetry. Nil disables it.
+	Metrics metrics.Factory
+}
+
+// Bool returns a pointer to v, for the optional bool fields of Configuration.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// IsDisabled reports whether Disabled is set to true.
+func (c *Configuration) IsDisabled() bool {
+	return c.Disabled != nil && *c.Disabled
+}
+
+// PprofLabelsEnabled reports whether PprofLabels is set to true.
+func (c *Configuration) PprofLabelsEnabled() bool {
+	return c.PprofLabels != nil && *c.PprofLabels
 }
@@ -0,0 +1,6 @@
+package config
+
+type IDGeneratorConfig struct {
+	Type   string `json:"type"`    // random (默认) 或 snowflake
+	NodeID int64  `json:"node_id"` // 仅 snowflake 使用，每个进程必须不同
+}
@@ -0,0 +1,397 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PropagationTracer = "tracer"
+	PropagationB3     = "b3"
+)
+
+// Environment variables read by FromEnv and Load.
+const (
+	EnvDisabled              = "TRACER_DISABLED"
+	EnvPprofLabels           = "TRACER_PPROF_LABELS"
+	EnvServiceName           = "TRACER_SERVICE_NAME"
+	EnvSamplerType           = "TRACER_SAMPLER_TYPE"
+	EnvSamplerParam          = "TRACER_SAMPLER_PARAM"
+	EnvAgentAddr             = "TRACER_AGENT_ADDR"
+	EnvReporterQueueSize     = "TRACER_REPORTER_QUEUE_SIZE"
+	EnvReporterFlushInterval = "TRACER_REPORTER_FLUSH_INTERVAL"
+	EnvPropagation           = "TRACER_PROPAGATION" // 逗号分隔，如 tracer,b3
+	EnvTags                  = "TRACER_TAGS"        // 逗号分隔的 key=value
+	EnvIDGeneratorType       = "TRACER_ID_GENERATOR_TYPE"
+	EnvIDGeneratorNodeID     = "TRACER_ID_GENERATOR_NODE_ID"
+	EnvResourceDisabled      = "TRACER_RESOURCE_DISABLED" // 逗号分隔的 detector 名称，all 表示全部关闭
+)
+
+// Default returns the configuration used when nothing else is set.
+func Default() *Configuration {
+	return &Configuration{
+		Sampler: &SamplerConfig{
+			Type:  "const",
+			Param: 1,
+		},
+		Reporter: &ReporterConfig{
+			QueueSize: 100,
+			Duration:  time.Second,
+			AgentAddr: "127.0.0.1:8888",
+		},
+		IDGenerator: &IDGeneratorConfig{
+			Type: "random",
+		},
+		Propagation: []string{PropagationTracer},
+	}
+}
+
+// FromEnv returns the defaults overridden by the TRACER_* environment variables.
+func FromEnv() (*Configuration, error) {
+	return Load("", nil)
+}
+
+// FromFile returns the defaults overridden by a YAML or JSON file.
+// The format is chosen by the file extension (.yaml, .yml or .json).
+func FromFile(path string) (*Configuration, error) {
+	c := Default()
+	if err := c.applyFile(path); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Load builds the configuration from every source.
+// Precedence is code > env > file > defaults; an empty path skips the file and a nil code skips the code.
+func Load(path string, code *Configuration) (*Configuration, error) {
+	c := Default()
+	if path != "" {
+		if err := c.applyFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	c.Merge(code)
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Merge overrides c with the fields set in o.
+// A non-nil Sampler replaces the sampler as a whole, since a zero param is meaningful,
+// and a non-nil Disabled or PprofLabels wins even when it is false.
+func (c *Configuration) Merge(o *Configuration) {
+	if o == nil {
+		return
+	}
+
+	if o.Disabled != nil {
+		c.Disabled = Bool(*o.Disabled)
+	}
+	if o.ServiceName != "" {
+		c.ServiceName = o.ServiceName
+	}
+	if o.Sampler != nil {
+		sampler := *o.Sampler
+		c.Sampler = &sampler
+	}
+	if o.Reporter != nil {
+		var reporter ReporterConfig
+		if c.Reporter != nil {
+			reporter = *c.Reporter
+		}
+		if o.Reporter.QueueSize != 0 {
+			reporter.QueueSize = o.Reporter.QueueSize
+		}
+		if o.Reporter.Duration != 0 {
+			reporter.Duration = o.Reporter.Duration
+		}
+		if o.Reporter.AgentAddr != "" {
+			reporter.AgentAddr = o.Reporter.AgentAddr
+		}
+		c.Reporter = &reporter
+	}
+	if o.IDGenerator != nil {
+		idGenerator := *o.IDGenerator
+		c.IDGenerator = &idGenerator
+	}
+	if len(o.Propagation) != 0 {
+		c.Propagation = o.Propagation
+	}
+	if len(o.Tags) != 0 {
+		c.Tags = o.Tags
+	}
+	if o.Resource != nil {
+		resource := *o.Resource
+		c.Resource = &resource
+	}
+	if o.PprofLabels != nil {
+		c.PprofLabels = Bool(*o.PprofLabels)
+	}
+	if o.Metrics != nil {
+		c.Metrics = o.Metrics
+	}
+}
+
+// Validate checks that the configuration can be used to build a tracer.
+func (c *Configuration) Validate() error {
+	if c.IsDisabled() {
+		return nil
+	}
+
+	if c.ServiceName == "" {
+		return fmt.Errorf("config: service name is required (set %s or service_name)", EnvServiceName)
+	}
+
+	if c.Sampler == nil {
+		return fmt.Errorf("config: sampler is required")
+	}
+	// only the const sampler is implemented; see sampler.NewSampler
+	switch c.Sampler.Type {
+	case "const":
+		if c.Sampler.Param != 0 && c.Sampler.Param != 1 {
+			return fmt.Errorf("config: const sampler param must be 0 or 1, got %v", c.Sampler.Param)
+		}
+	default:
+		return fmt.Errorf("config: sampler type %q is not supported", c.Sampler.Type)
+	}
+
+	if c.Reporter == nil {
+		return fmt.Errorf("config: reporter is required")
+	}
+	if c.Reporter.AgentAddr == "" {
+		return fmt.Errorf("config: reporter agent address is required")
+	}
+	if c.Reporter.QueueSize == 0 {
+		return fmt.Errorf("config: reporter queue size must be greater than 0")
+	}
+	if c.Reporter.Duration <= 0 {
+		return fmt.Errorf("config: reporter flush interval must be greater than 0, got %v", c.Reporter.Duration)
+	}
+
+	for _, format := range c.Propagation {
+		if format != PropagationTracer && format != PropagationB3 {
+			return fmt.Errorf("config: unknown propagation format %q", format)
+		}
+	}
+
+	return nil
+}
+
+// fileConfiguration is the on-disk layout. Pointers tell "not set" apart from zero values.
+type fileConfiguration struct {
+	Disabled    *bool   `json:"disabled" yaml:"disabled"`
+	ServiceName *string `json:"service_name" yaml:"service_name"`
+	Sampler     *struct {
+		Type  *string  `json:"type" yaml:"type"`
+		Param *float64 `json:"param" yaml:"param"`
+	} `json:"sampler" yaml:"sampler"`
+	Reporter *struct {
+		AgentAddr     *string `json:"agent_addr" yaml:"agent_addr"`
+		QueueSize     *uint   `json:"queue_size" yaml:"queue_size"`
+		FlushInterval *string `json:"flush_interval" yaml:"flush_interval"`
+	} `json:"reporter" yaml:"reporter"`
+	IDGenerator *struct {
+		Type   *string `json:"type" yaml:"type"`
+		NodeID *int64  `json:"node_id" yaml:"node_id"`
+	} `json:"id_generator" yaml:"id_generator"`
+	Propagation []string          `json:"propagation" yaml:"propagation"`
+	Tags        map[string]string `json:"tags" yaml:"tags"`
+	PprofLabels *bool             `json:"pprof_labels" yaml:"pprof_labels"`
+	Resource    *struct {
+		Disabled []string `json:"disabled" yaml:"disabled"`
+	} `json:"resource" yaml:"resource"`
+}
+
+// applyFile overrides c with the fields set in the file.
+func (c *Configuration) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	var fc fileConfiguration
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fc)
+	case ".json":
+		err = json.Unmarshal(data, &fc)
+	default:
+		return fmt.Errorf("config: unsupported file type %q, want .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+
+	if fc.Disabled != nil {
+		c.Disabled = fc.Disabled
+	}
+	if fc.ServiceName != nil {
+		c.ServiceName = *fc.ServiceName
+	}
+	if fc.Sampler != nil {
+		if fc.Sampler.Type != nil {
+			c.Sampler.Type = *fc.Sampler.Type
+		}
+		if fc.Sampler.Param != nil {
+			c.Sampler.Param = *fc.Sampler.Param
+		}
+	}
+	if fc.Reporter != nil {
+		if fc.Reporter.AgentAddr != nil {
+			c.Reporter.AgentAddr = *fc.Reporter.AgentAddr
+		}
+		if fc.Reporter.QueueSize != nil {
+			c.Reporter.QueueSize = *fc.Reporter.QueueSize
+		}
+		if fc.Reporter.FlushInterval != nil {
+			d, err := time.ParseDuration(*fc.Reporter.FlushInterval)
+			if err != nil {
+				return fmt.Errorf("config: %s: reporter.flush_interval: %w", path, err)
+			}
+			c.Reporter.Duration = d
+		}
+	}
+	if fc.IDGenerator != nil {
+		if fc.IDGenerator.Type != nil {
+			c.IDGenerator.Type = *fc.IDGenerator.Type
+		}
+		if fc.IDGenerator.NodeID != nil {
+			c.IDGenerator.NodeID = *fc.IDGenerator.NodeID
+		}
+	}
+	if len(fc.Propagation) != 0 {
+		c.Propagation = fc.Propagation
+	}
+	if len(fc.Tags) != 0 {
+		c.Tags = mapToTags(fc.Tags)
+	}
+	if fc.PprofLabels != nil {
+		c.PprofLabels = fc.PprofLabels
+	}
+	if fc.Resource != nil {
+		c.Resource = &ResourceConfig{Disabled: fc.Resource.Disabled}
+	}
+
+	return nil
+}
+
+// applyEnv overrides c with the TRACER_* environment variables that are set.
+func (c *Configuration) applyEnv() error {
+	if v, ok := os.LookupEnv(EnvDisabled); ok {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", EnvDisabled, err)
+		}
+		c.Disabled = Bool(disabled)
+	}
+	if v, ok := os.LookupEnv(EnvPprofLabels); ok {
+		pprofLabels, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", EnvPprofLabels, err)
+		}
+		c.PprofLabels = Bool(pprofLabels)
+	}
+	if v, ok := os.LookupEnv(EnvServiceName); ok {
+		c.ServiceName = v
+	}
+	if v, ok := os.LookupEnv(EnvSamplerType); ok {
+		c.Sampler.Type = v
+	}
+	if v, ok := os.LookupEnv(EnvSamplerParam); ok {
+		param, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", EnvSamplerParam, err)
+		}
+		c.Sampler.Param = param
+	}
+	if v, ok := os.LookupEnv(EnvAgentAddr); ok {
+		c.Reporter.AgentAddr = v
+	}
+	if v, ok := os.LookupEnv(EnvReporterQueueSize); ok {
+		size, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", EnvReporterQueueSize, err)
+		}
+		c.Reporter.QueueSize = uint(size)
+	}
+	if v, ok := os.LookupEnv(EnvReporterFlushInterval); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", EnvReporterFlushInterval, err)
+		}
+		c.Reporter.Duration = d
+	}
+	if v, ok := os.LookupEnv(EnvIDGeneratorType); ok {
+		c.IDGenerator.Type = v
+	}
+	if v, ok := os.LookupEnv(EnvIDGeneratorNodeID); ok {
+		nodeID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", EnvIDGeneratorNodeID, err)
+		}
+		c.IDGenerator.NodeID = nodeID
+	}
+	if v, ok := os.LookupEnv(EnvPropagation); ok {
+		c.Propagation = splitList(v)
+	}
+	if v, ok := os.LookupEnv(EnvResourceDisabled); ok {
+		c.Resource = &ResourceConfig{Disabled: splitList(v)}
+	}
+	if v, ok := os.LookupEnv(EnvTags); ok {
+		tags := make(map[string]string)
+		for _, pair := range splitList(v) {
+			key, value, found := strings.Cut(pair, "=")
+			if !found || key == "" {
+				return fmt.Errorf("config: %s: %q is not key=value", EnvTags, pair)
+			}
+			tags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		c.Tags = mapToTags(tags)
+	}
+
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// mapToTags converts a map to tags sorted by key, so the tags keep the same order on every load.
+func mapToTags(m map[string]string) []Tag {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]Tag, 0, len(m))
+	for _, k := range keys {
+		tags = append(tags, Tag{Key: k, Value: m[k]})
+	}
+	return tags
+}
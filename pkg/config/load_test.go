@@ -0,0 +1,327 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(EnvServiceName, "env-service")
+	t.Setenv(EnvSamplerParam, "0")
+	t.Setenv(EnvAgentAddr, "agent:6831")
+	t.Setenv(EnvReporterQueueSize, "50")
+	t.Setenv(EnvReporterFlushInterval, "250ms")
+	t.Setenv(EnvPropagation, "b3, tracer")
+	t.Setenv(EnvTags, "region=eu, zone = a")
+	t.Setenv(EnvPprofLabels, "true")
+	t.Setenv(EnvResourceDisabled, "ip,container")
+
+	c, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.ServiceName != "env-service" {
+		t.Errorf("ServiceName = %q, want env-service", c.ServiceName)
+	}
+	if c.Sampler.Type != "const" || c.Sampler.Param != 0 {
+		t.Errorf("Sampler = %+v, want const 0", *c.Sampler)
+	}
+	if c.Reporter.AgentAddr != "agent:6831" || c.Reporter.QueueSize != 50 || c.Reporter.Duration != 250*time.Millisecond {
+		t.Errorf("Reporter = %+v", *c.Reporter)
+	}
+	if !reflect.DeepEqual(c.Propagation, []string{PropagationB3, PropagationTracer}) {
+		t.Errorf("Propagation = %v, want [b3 tracer]", c.Propagation)
+	}
+	if want := []Tag{{Key: "region", Value: "eu"}, {Key: "zone", Value: "a"}}; !reflect.DeepEqual(c.Tags, want) {
+		t.Errorf("Tags = %v, want %v", c.Tags, want)
+	}
+	if !c.PprofLabelsEnabled() {
+		t.Error("PprofLabels is off, want on")
+	}
+	if !reflect.DeepEqual(c.Resource.Disabled, []string{"ip", "container"}) {
+		t.Errorf("Resource.Disabled = %v, want [ip container]", c.Resource.Disabled)
+	}
+}
+
+func TestFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		key, value string
+	}{
+		{EnvDisabled, "maybe"},
+		{EnvSamplerParam, "half"},
+		{EnvReporterQueueSize, "-1"},
+		{EnvReporterFlushInterval, "soon"},
+		{EnvTags, "region"},
+		{EnvIDGeneratorNodeID, "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Setenv(EnvServiceName, "svc")
+			t.Setenv(tt.key, tt.value)
+			if _, err := FromEnv(); err == nil {
+				t.Fatalf("%s=%q accepted, want an error", tt.key, tt.value)
+			}
+		})
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"tracer.yaml", `
+service_name: file-service
+sampler:
+  param: 0
+reporter:
+  agent_addr: agent:6831
+  flush_interval: 2s
+id_generator:
+  type: snowflake
+  node_id: 7
+propagation: [b3]
+tags:
+  region: eu
+pprof_labels: true
+`},
+		{"tracer.json", `{
+  "service_name": "file-service",
+  "sampler": {"param": 0},
+  "reporter": {"agent_addr": "agent:6831", "flush_interval": "2s"},
+  "id_generator": {"type": "snowflake", "node_id": 7},
+  "propagation": ["b3"],
+  "tags": {"region": "eu"},
+  "pprof_labels": true
+}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := FromFile(writeFile(t, tt.name, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.ServiceName != "file-service" {
+				t.Errorf("ServiceName = %q, want file-service", c.ServiceName)
+			}
+			// fields missing from the file keep their defaults
+			if c.Sampler.Type != "const" || c.Sampler.Param != 0 {
+				t.Errorf("Sampler = %+v, want const 0", *c.Sampler)
+			}
+			if c.Reporter.AgentAddr != "agent:6831" || c.Reporter.QueueSize != 100 || c.Reporter.Duration != 2*time.Second {
+				t.Errorf("Reporter = %+v", *c.Reporter)
+			}
+			if c.IDGenerator.Type != "snowflake" || c.IDGenerator.NodeID != 7 {
+				t.Errorf("IDGenerator = %+v", *c.IDGenerator)
+			}
+			if !reflect.DeepEqual(c.Propagation, []string{PropagationB3}) {
+				t.Errorf("Propagation = %v, want [b3]", c.Propagation)
+			}
+			if want := []Tag{{Key: "region", Value: "eu"}}; !reflect.DeepEqual(c.Tags, want) {
+				t.Errorf("Tags = %v, want %v", c.Tags, want)
+			}
+			if !c.PprofLabelsEnabled() {
+				t.Error("PprofLabels is off, want on")
+			}
+		})
+	}
+}
+
+func TestFromFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"tracer.toml", `service_name = "x"`},
+		{"tracer.yaml", "service_name: [unclosed"},
+		{"tracer.json", `{"service_name": "x", "reporter": {"flush_interval": "soon"}}`},
+		// valid syntax, but the service name is required
+		{"empty.json", `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromFile(writeFile(t, tt.name, tt.content)); err == nil {
+				t.Fatalf("%s accepted, want an error", tt.name)
+			}
+		})
+	}
+
+	if _, err := FromFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("missing file accepted, want an error")
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "tracer.yaml", `
+service_name: file-service
+disabled: true
+pprof_labels: true
+reporter:
+  agent_addr: file:6831
+  queue_size: 10
+`)
+	t.Setenv(EnvServiceName, "env-service")
+	t.Setenv(EnvAgentAddr, "env:6831")
+
+	tests := []struct {
+		name            string
+		code            *Configuration
+		wantService     string
+		wantAddr        string
+		wantQueueSize   uint
+		wantDisabled    bool
+		wantPprofLabels bool
+	}{
+		{
+			name:            "env over file",
+			code:            nil,
+			wantService:     "env-service",
+			wantAddr:        "env:6831",
+			wantQueueSize:   10,
+			wantDisabled:    true,
+			wantPprofLabels: true,
+		},
+		{
+			name: "code over env",
+			code: &Configuration{
+				ServiceName: "code-service",
+				Reporter:    &ReporterConfig{AgentAddr: "code:6831"},
+			},
+			wantService:     "code-service",
+			wantAddr:        "code:6831",
+			wantQueueSize:   10,
+			wantDisabled:    true,
+			wantPprofLabels: true,
+		},
+		{
+			name: "explicit false in code turns the file's true off",
+			code: &Configuration{
+				Disabled:    Bool(false),
+				PprofLabels: Bool(false),
+			},
+			wantService:     "env-service",
+			wantAddr:        "env:6831",
+			wantQueueSize:   10,
+			wantDisabled:    false,
+			wantPprofLabels: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(path, tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.ServiceName != tt.wantService {
+				t.Errorf("ServiceName = %q, want %q", c.ServiceName, tt.wantService)
+			}
+			if c.Reporter.AgentAddr != tt.wantAddr {
+				t.Errorf("AgentAddr = %q, want %q", c.Reporter.AgentAddr, tt.wantAddr)
+			}
+			if c.Reporter.QueueSize != tt.wantQueueSize {
+				t.Errorf("QueueSize = %d, want %d", c.Reporter.QueueSize, tt.wantQueueSize)
+			}
+			if c.IsDisabled() != tt.wantDisabled {
+				t.Errorf("IsDisabled = %v, want %v", c.IsDisabled(), tt.wantDisabled)
+			}
+			if c.PprofLabelsEnabled() != tt.wantPprofLabels {
+				t.Errorf("PprofLabelsEnabled = %v, want %v", c.PprofLabelsEnabled(), tt.wantPprofLabels)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	c := Default()
+	c.Disabled = Bool(true)
+	c.PprofLabels = Bool(true)
+
+	// unset fields leave c alone
+	c.Merge(&Configuration{})
+	if !c.IsDisabled() || !c.PprofLabelsEnabled() {
+		t.Fatal("merging an empty configuration changed the bools")
+	}
+	if c.Reporter.QueueSize != 100 || c.Sampler.Param != 1 {
+		t.Fatal("merging an empty configuration changed the defaults")
+	}
+
+	c.Merge(&Configuration{
+		Disabled: Bool(false),
+		Sampler:  &SamplerConfig{Type: "const", Param: 0},
+		Reporter: &ReporterConfig{QueueSize: 5},
+	})
+	if c.IsDisabled() {
+		t.Error("Disabled is still true after merging an explicit false")
+	}
+	if !c.PprofLabelsEnabled() {
+		t.Error("PprofLabels was reset although it was not set")
+	}
+	if c.Sampler.Param != 0 {
+		t.Errorf("Sampler.Param = %v, want the merged 0", c.Sampler.Param)
+	}
+	if c.Reporter.QueueSize != 5 || c.Reporter.AgentAddr != "127.0.0.1:8888" {
+		t.Errorf("Reporter = %+v, want queue size 5 and the default address", *c.Reporter)
+	}
+
+	// the merged configuration does not share pointers with the source
+	o := &Configuration{Disabled: Bool(true)}
+	c.Merge(o)
+	*o.Disabled = false
+	if !c.IsDisabled() {
+		t.Error("changing the source after Merge changed the result")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Configuration {
+		c := Default()
+		c.ServiceName = "svc"
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Configuration)
+		wantErr bool
+	}{
+		{"defaults with a service name", func(*Configuration) {}, false},
+		{"missing service name", func(c *Configuration) { c.ServiceName = "" }, true},
+		{"disabled skips the checks", func(c *Configuration) { c.ServiceName = ""; c.Disabled = Bool(true) }, false},
+		{"unknown sampler", func(c *Configuration) { c.Sampler.Type = "rate" }, true},
+		{"probabilistic sampler not implemented", func(c *Configuration) { c.Sampler.Type = "probabilistic"; c.Sampler.Param = 0.5 }, true},
+		{"const param out of range", func(c *Configuration) { c.Sampler.Param = 0.5 }, true},
+		{"missing agent address", func(c *Configuration) { c.Reporter.AgentAddr = "" }, true},
+		{"zero queue size", func(c *Configuration) { c.Reporter.QueueSize = 0 }, true},
+		{"zero flush interval", func(c *Configuration) { c.Reporter.Duration = 0 }, true},
+		{"unknown propagation", func(c *Configuration) { c.Propagation = []string{"w3c"} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package config
+
+type ResourceConfig struct {
+	// Disabled lists detectors to skip by name; "all" turns detection off.
+	Disabled []string `json:"disabled"`
+}
@@ -0,0 +1,71 @@
+package metrics
+
+// Values of the agent_exporter_breaker_state gauge.
+const (
+	BreakerClosed   = 0
+	BreakerHalfOpen = 1
+	BreakerOpen     = 2
+)
+
+// AgentMetrics is the self-telemetry of the agent.
+type AgentMetrics struct {
+	factory Factory
+
+	// PacketsReceived counts UDP datagrams and HTTP/OTLP requests received.
+	PacketsReceived Counter
+	// PacketsDroppedBusy counts datagrams dropped because every decode worker was busy.
+	PacketsDroppedBusy Counter
+	// PacketsDroppedFull counts packages dropped because the aggregator was saturated.
+	PacketsDroppedFull Counter
+	// DecodeErrors counts datagrams and request bodies that could not be decoded.
+	DecodeErrors Counter
+
+	// ExportLatency is the duration of one Export call to the collector.
+	ExportLatency Timer
+	// ExportSuccess counts batches accepted by the collector.
+	ExportSuccess Counter
+	// ExportErrors counts Export calls that failed, including each failed retry.
+	ExportErrors Counter
+	// ExportRejected counts batches the collector rejected for good; they are dropped, not queued.
+	ExportRejected Counter
+	// ExportRetries counts Export calls repeated after a retryable error.
+	ExportRetries Counter
+}
+
+// NewAgentMetrics creates the agent metrics with the given factory.
+// A nil factory falls back to NullFactory.
+func NewAgentMetrics(factory Factory) *AgentMetrics {
+	if factory == nil {
+		factory = NullFactory
+	}
+
+	return &AgentMetrics{
+		factory:            factory,
+		PacketsReceived:    factory.Counter("agent_packets_received", nil),
+		PacketsDroppedBusy: factory.Counter("agent_packets_dropped", map[string]string{"reason": "workers_busy"}),
+		PacketsDroppedFull: factory.Counter("agent_packets_dropped", map[string]string{"reason": "aggregator_full"}),
+		DecodeErrors:       factory.Counter("agent_decode_errors", nil),
+		ExportLatency:      factory.Timer("agent_exporter_export_latency", nil),
+		ExportSuccess:      factory.Counter("agent_exporter_batches", map[string]string{"result": "ok"}),
+		ExportErrors:       factory.Counter("agent_exporter_batches", map[string]string{"result": "err"}),
+		ExportRejected:     factory.Counter("agent_exporter_rejected_batches", nil),
+		ExportRetries:      factory.Counter("agent_exporter_retries", nil),
+	}
+}
+
+// BreakerState returns the gauge for the circuit breaker of one collector:
+// BreakerClosed, BreakerHalfOpen or BreakerOpen.
+func (m *AgentMetrics) BreakerState(collector string) Gauge {
+	return m.factory.Gauge("agent_exporter_breaker_state", map[string]string{"collector": collector})
+}
+
+// QueueDepth returns the gauge for the number of items waiting in one of the agent channels.
+func (m *AgentMetrics) QueueDepth(queue string) Gauge {
+	return m.factory.Gauge("agent_queue_depth", map[string]string{"queue": queue})
+}
+
+// AggregatorBufferedSpans returns the gauge for the spans the aggregator holds for one service.
+// Callers must bound the number of distinct services, which come from clients.
+func (m *AgentMetrics) AggregatorBufferedSpans(service string) Gauge {
+	return m.factory.Gauge("agent_aggregator_buffered_spans", map[string]string{"service": service})
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// ExpvarFactory publishes metrics through the standard expvar package.
+// Every metric name becomes an expvar.Map keyed by its tag set.
+type ExpvarFactory struct {
+	mu   sync.Mutex
+	maps map[string]*expvar.Map
+}
+
+// NewExpvarFactory creates a new ExpvarFactory.
+func NewExpvarFactory() *ExpvarFactory {
+	return &ExpvarFactory{
+		maps: make(map[string]*expvar.Map),
+	}
+}
+
+// Counter returns an expvar-backed Counter.
+func (f *ExpvarFactory) Counter(name string, tags map[string]string) Counter {
+	return &expvarInt{v: f.get(name, seriesKey("", tags), new(expvar.Int)).(*expvar.Int)}
+}
+
+// Gauge returns an expvar-backed Gauge.
+func (f *ExpvarFactory) Gauge(name string, tags map[string]string) Gauge {
+	return &expvarInt{v: f.get(name, seriesKey("", tags), new(expvar.Int)).(*expvar.Int)}
+}
+
+// Timer returns an expvar-backed Timer which keeps the count and the total in microseconds.
+func (f *ExpvarFactory) Timer(name string, tags map[string]string) Timer {
+	key := seriesKey("", tags)
+	return &expvarTimer{
+		count: f.get(name+"_count", key, new(expvar.Int)).(*expvar.Int),
+		sumUs: f.get(name+"_sum_us", key, new(expvar.Int)).(*expvar.Int),
+	}
+}
+
+// get returns the variable stored under name/key, creating it if needed.
+func (f *ExpvarFactory) get(name, key string, v expvar.Var) expvar.Var {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, ok := f.maps[name]
+	if !ok {
+		// expvar.Publish panics on duplicated names, so reuse what is already there.
+		if existing, isMap := expvar.Get(name).(*expvar.Map); isMap {
+			m = existing
+		} else {
+			m = expvar.NewMap(name)
+		}
+		f.maps[name] = m
+	}
+
+	if existing := m.Get(key); existing != nil {
+		return existing
+	}
+
+	m.Set(key, v)
+	return v
+}
+
+type expvarInt struct {
+	v *expvar.Int
+}
+
+func (e *expvarInt) Inc(delta int64) {
+	e.v.Add(delta)
+}
+
+func (e *expvarInt) Update(value int64) {
+	e.v.Set(value)
+}
+
+type expvarTimer struct {
+	count *expvar.Int
+	sumUs *expvar.Int
+}
+
+func (e *expvarTimer) Record(d time.Duration) {
+	e.count.Add(1)
+	e.sumUs.Add(d.Microseconds())
+}
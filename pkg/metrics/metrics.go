@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Counter tracks a value that only goes up.
+type Counter interface {
+	Inc(delta int64)
+}
+
+// Gauge tracks a value that can go up and down.
+type Gauge interface {
+	Update(value int64)
+}
+
+// Timer records how long an operation took.
+type Timer interface {
+	Record(d time.Duration)
+}
+
+// Factory creates metrics. Implementations must return the same metric
+// when called again with the same name and tags.
+type Factory interface {
+	Counter(name string, tags map[string]string) Counter
+	Gauge(name string, tags map[string]string) Gauge
+	Timer(name string, tags map[string]string) Timer
+}
+
+// NullFactory discards every measurement. It is used when no factory is configured.
+var NullFactory Factory = nullFactory{}
+
+type nullFactory struct{}
+
+func (nullFactory) Counter(string, map[string]string) Counter { return nullMetric{} }
+func (nullFactory) Gauge(string, map[string]string) Gauge     { return nullMetric{} }
+func (nullFactory) Timer(string, map[string]string) Timer     { return nullMetric{} }
+
+type nullMetric struct{}
+
+func (nullMetric) Inc(int64)            {}
+func (nullMetric) Update(int64)         {}
+func (nullMetric) Record(time.Duration) {}
+
+// seriesKey builds a stable key like name{a="1",b="2"} with tags sorted by key.
+func seriesKey(name string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(tags[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpvarFactory(t *testing.T) {
+	f := NewExpvarFactory()
+
+	f.Counter("test_expvar_batches", map[string]string{"result": "ok"}).Inc(2)
+	f.Counter("test_expvar_batches", map[string]string{"result": "ok"}).Inc(1)
+	f.Counter("test_expvar_batches", map[string]string{"result": "err"}).Inc(1)
+	f.Gauge("test_expvar_queue", nil).Update(7)
+	f.Timer("test_expvar_latency", nil).Record(1500 * time.Microsecond)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"test_expvar_batches", `{"{result=\"err\"}": 1, "{result=\"ok\"}": 3}`},
+		{"test_expvar_queue", `{"": 7}`},
+		{"test_expvar_latency_count", `{"": 1}`},
+		{"test_expvar_latency_sum_us", `{"": 1500}`},
+	}
+	for _, tt := range tests {
+		v := expvar.Get(tt.name)
+		if v == nil {
+			t.Fatalf("%s is not published", tt.name)
+		}
+		if got := v.String(); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpvarFactoryReusesPublishedMaps(t *testing.T) {
+	NewExpvarFactory().Counter("test_expvar_shared", nil).Inc(1)
+	// a second factory must not panic on the existing name and keeps adding to it
+	NewExpvarFactory().Counter("test_expvar_shared", nil).Inc(1)
+
+	if got := expvar.Get("test_expvar_shared").String(); got != `{"": 2}` {
+		t.Fatalf("test_expvar_shared = %s, want {\"\": 2}", got)
+	}
+}
+
+func TestPrometheusFactory(t *testing.T) {
+	f := NewPrometheusFactory()
+
+	f.Counter("tracer_reporter_batches", map[string]string{"result": "ok"}).Inc(3)
+	f.Counter("tracer_reporter_batches", map[string]string{"result": "err"}).Inc(1)
+	f.Gauge("agent.queue-depth", map[string]string{"queue": `a"b`}).Update(5)
+	f.Timer("tracer_reporter_send_latency", nil).Record(1500 * time.Millisecond)
+
+	want := `# TYPE agent_queue_depth gauge
+agent_queue_depth{queue="a\"b"} 5
+# TYPE tracer_reporter_batches_total counter
+tracer_reporter_batches_total{result="err"} 1
+tracer_reporter_batches_total{result="ok"} 3
+# TYPE tracer_reporter_send_latency_seconds summary
+tracer_reporter_send_latency_seconds_sum 1.5
+tracer_reporter_send_latency_seconds_count 1
+`
+
+	var b strings.Builder
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != want {
+		t.Fatalf("WriteTo wrote\n%s\nwant\n%s", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	f.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	if rec.Body.String() != want {
+		t.Fatalf("ServeHTTP wrote\n%s\nwant\n%s", rec.Body.String(), want)
+	}
+}
+
+func TestPrometheusFactoryNames(t *testing.T) {
+	f := NewPrometheusFactory()
+
+	// 已经带后缀的名字不会再加一次
+	f.Counter("requests_total", nil).Inc(1)
+	f.Counter("requests", nil).Inc(1)
+	f.Timer("wait_seconds", nil).Record(time.Second)
+
+	var b strings.Builder
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"requests_total 2\n", "wait_seconds_count 1\n"} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("output has no line %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestPrometheusFactoryTypeClash(t *testing.T) {
+	f := NewPrometheusFactory()
+	f.Gauge("queue_total", nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a counter named like an existing gauge did not panic")
+		}
+	}()
+	f.Counter("queue", nil)
+}
+
+func TestTracerMetricsSharedSeries(t *testing.T) {
+	f := NewPrometheusFactory()
+	m := NewTracerMetrics(f)
+
+	m.SpansStarted.Inc(1)
+	m.SamplerDecision("const", true).Inc(1)
+	m.SamplerDecision("const", true).Inc(1)
+
+	var b strings.Builder
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		"tracer_spans_started_total 1\n",
+		`tracer_sampler_decisions_total{sampled="true",sampler_type="const"} 2` + "\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("output has no line %q:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+	typeSummary = "summary"
+)
+
+// PrometheusFactory keeps metrics in memory and renders them in the
+// Prometheus text exposition format. It does not depend on the Prometheus client library.
+// Names follow the Prometheus conventions: counters end in _total and timers in _seconds,
+// and the suffix is added when the name does not have it yet.
+type PrometheusFactory struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	typ    string
+	series map[string]*series
+}
+
+type series struct {
+	labels string
+	value  atomic.Int64
+	count  atomic.Int64 // only used by timers
+}
+
+// NewPrometheusFactory creates a new PrometheusFactory.
+func NewPrometheusFactory() *PrometheusFactory {
+	return &PrometheusFactory{
+		families: make(map[string]*family),
+	}
+}
+
+// Counter returns a Counter exported as a Prometheus counter named name_total.
+func (f *PrometheusFactory) Counter(name string, tags map[string]string) Counter {
+	return &promCounter{s: f.get(name, typeCounter, tags)}
+}
+
+// Gauge returns a Gauge exported as a Prometheus gauge.
+func (f *PrometheusFactory) Gauge(name string, tags map[string]string) Gauge {
+	return &promGauge{s: f.get(name, typeGauge, tags)}
+}
+
+// Timer returns a Timer exported as a Prometheus summary named name_seconds (sum in seconds and count).
+func (f *PrometheusFactory) Timer(name string, tags map[string]string) Timer {
+	return &promTimer{s: f.get(name, typeSummary, tags)}
+}
+
+// get returns the series for name and tags, creating the family and the series if needed.
+// It panics if name is already used by a metric of another type, which would make the output invalid.
+func (f *PrometheusFactory) get(name, typ string, tags map[string]string) *series {
+	name = sanitizeName(name)
+	switch typ {
+	case typeCounter:
+		name = withSuffix(name, "_total")
+	case typeSummary:
+		name = withSuffix(name, "_seconds")
+	}
+	clean := make(map[string]string, len(tags))
+	for k, v := range tags {
+		clean[sanitizeName(k)] = v
+	}
+	labels := seriesKey("", clean)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fam, ok := f.families[name]
+	if !ok {
+		fam = &family{typ: typ, series: make(map[string]*series)}
+		f.families[name] = fam
+	}
+	if fam.typ != typ {
+		panic(fmt.Sprintf("metrics: %s is already registered as a %s, not a %s", name, fam.typ, typ))
+	}
+
+	s, ok := fam.series[labels]
+	if !ok {
+		s = &series{labels: labels}
+		fam.series[labels] = s
+	}
+
+	return s
+}
+
+// WriteTo writes every metric in the Prometheus text format.
+func (f *PrometheusFactory) WriteTo(w io.Writer) (int64, error) {
+	f.mu.Lock()
+	names := make([]string, 0, len(f.families))
+	for name := range f.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fam := f.families[name]
+		keys := make([]string, 0, len(fam.series))
+		for k := range fam.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, fam.typ)
+		for _, k := range keys {
+			s := fam.series[k]
+			switch fam.typ {
+			case typeSummary:
+				fmt.Fprintf(&b, "%s_sum%s %g\n", name, s.labels, time.Duration(s.value.Load()).Seconds())
+				fmt.Fprintf(&b, "%s_count%s %d\n", name, s.labels, s.count.Load())
+			default:
+				fmt.Fprintf(&b, "%s%s %d\n", name, s.labels, s.value.Load())
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics so the factory can be mounted on /metrics.
+func (f *PrometheusFactory) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = f.WriteTo(w)
+}
+
+// sanitizeName replaces characters that are not allowed in Prometheus metric names.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+func withSuffix(name, suffix string) string {
+	if strings.HasSuffix(name, suffix) {
+		return name
+	}
+	return name + suffix
+}
+
+type promCounter struct {
+	s *series
+}
+
+func (c *promCounter) Inc(delta int64) {
+	c.s.value.Add(delta)
+}
+
+type promGauge struct {
+	s *series
+}
+
+func (g *promGauge) Update(value int64) {
+	g.s.value.Store(value)
+}
+
+type promTimer struct {
+	s *series
+}
+
+func (t *promTimer) Record(d time.Duration) {
+	t.s.value.Add(int64(d))
+	t.s.count.Add(1)
+}
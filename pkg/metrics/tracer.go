@@ -0,0 +1,60 @@
+package metrics
+
+// TracerMetrics is the self-telemetry of the tracer SDK.
+// It is shared by the Tracer, the Reporter and the Batch.
+type TracerMetrics struct {
+	factory Factory
+
+	// SpansStarted counts every span created by the tracer.
+	SpansStarted Counter
+	// SpansFinished counts sampled spans that finished, including the ones a span processor
+	// then dropped before the reporter.
+	SpansFinished Counter
+	// SpansDropped counts spans that were lost before reaching the agent:
+	// pushed while the batch was full, or in a batch that failed to encode or to be written.
+	SpansDropped Counter
+	// SpansMutatedAfterFinish counts calls that tried to change (or finish again) a finished span.
+	SpansMutatedAfterFinish Counter
+
+	// ReporterQueueLength is the number of spans waiting in the batch.
+	ReporterQueueLength Gauge
+	// ReporterSendLatency is the time spent encoding and writing one batch.
+	ReporterSendLatency Timer
+	// ReporterSuccess counts batches written to the agent.
+	ReporterSuccess Counter
+	// ReporterErrors counts batches that failed to encode or to be written over UDP.
+	ReporterErrors Counter
+}
+
+// NewTracerMetrics creates the SDK metrics with the given factory.
+// A nil factory falls back to NullFactory.
+func NewTracerMetrics(factory Factory) *TracerMetrics {
+	if factory == nil {
+		factory = NullFactory
+	}
+
+	return &TracerMetrics{
+		factory:                 factory,
+		SpansStarted:            factory.Counter("tracer_spans_started", nil),
+		SpansFinished:           factory.Counter("tracer_spans_finished", nil),
+		SpansDropped:            factory.Counter("tracer_spans_dropped", nil),
+		SpansMutatedAfterFinish: factory.Counter("tracer_spans_mutated_after_finish", nil),
+		ReporterQueueLength:     factory.Gauge("tracer_reporter_queue_length", nil),
+		ReporterSendLatency:     factory.Timer("tracer_reporter_send_latency", nil),
+		ReporterSuccess:         factory.Counter("tracer_reporter_batches", map[string]string{"result": "ok"}),
+		ReporterErrors:          factory.Counter("tracer_reporter_batches", map[string]string{"result": "err"}),
+	}
+}
+
+// SamplerDecision returns the counter for one sampling decision of the given sampler type.
+func (m *TracerMetrics) SamplerDecision(samplerType string, sampled bool) Counter {
+	decision := "false"
+	if sampled {
+		decision = "true"
+	}
+
+	return m.factory.Counter("tracer_sampler_decisions", map[string]string{
+		"sampler_type": samplerType,
+		"sampled":      decision,
+	})
+}
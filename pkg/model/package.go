@@ -19,6 +19,7 @@ type FlatSpan struct {
 	SpanID        string                 `json:"span_id"`
 	ParentSpanID  string                 `json:"parent_id"` // 方便直接查父节点
 	Sampled       bool                   `json:"sampled"`
+	Debug         bool                   `json:"debug"` // 强制采样的 trace，tail sampler 必须保留
 	Baggage       map[string]interface{} `json:"baggage"`
 	OperationName string                 `json:"operation"`
 	StartTime     int64                  `json:"start_time"` // 建议统一用微秒，方便计算
@@ -28,10 +28,19 @@ type StorageSpan struct {
 	EventTimesUs []int64
 	EventAttrs   []map[string]string
 
+	// Links / References（拆列，对应 ClickHouse 的 Links Nested 列）
+	LinkTraceIDs []string
+	LinkSpanIDs  []string
+	LinkTypes    []string
+	LinkAttrs    []map[string]string
+
 	// Process / Resource
 	ProcessID     string
 	ResourceAttrs map[string]string
 
 	// 用于分区 & TTL
 	TimestampUs int64
+
+	// Debug 表示强制采样，只给 tail sampler 用，不落库
+	Debug bool
 }
@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"context"
+	"tracer/pkg/config"
+	"tracer/pkg/span"
+)
+
+// TagProcessor adds static tags to every span.
+type TagProcessor struct {
+	tags []config.Tag
+}
+
+// NewTagProcessor creates a new TagProcessor.
+func NewTagProcessor(tags ...config.Tag) *TagProcessor {
+	return &TagProcessor{tags: tags}
+}
+
+func (p *TagProcessor) OnStart(_ context.Context, s *span.Span) {
+	for _, tag := range p.tags {
+		s.SetTag(tag.Key, tag.Value)
+	}
+}
+
+func (p *TagProcessor) OnEnd(*span.ToModel) {}
+
+// BaggageProcessor copies baggage items (e.g. a tenant ID set by an upstream service) into span tags.
+type BaggageProcessor struct {
+	keys []string
+}
+
+// NewBaggageProcessor creates a new BaggageProcessor for the given baggage keys.
+func NewBaggageProcessor(keys ...string) *BaggageProcessor {
+	return &BaggageProcessor{keys: keys}
+}
+
+func (p *BaggageProcessor) OnStart(_ context.Context, s *span.Span) {
+	for _, key := range p.keys {
+		if value := s.GetBaggageItem(key); value != "" {
+			s.SetTag(key, value)
+		}
+	}
+}
+
+func (p *BaggageProcessor) OnEnd(*span.ToModel) {}
@@ -0,0 +1,24 @@
+package processor
+
+import (
+	"context"
+	"tracer/pkg/span"
+)
+
+// FilterProcessor drops the spans for which keep returns false.
+type FilterProcessor struct {
+	keep func(s *span.ToModel) bool
+}
+
+// NewFilterProcessor creates a new FilterProcessor.
+func NewFilterProcessor(keep func(s *span.ToModel) bool) *FilterProcessor {
+	return &FilterProcessor{keep: keep}
+}
+
+func (p *FilterProcessor) OnStart(context.Context, *span.Span) {}
+
+func (p *FilterProcessor) OnEnd(s *span.ToModel) {
+	if s.Context.Sampled && !p.keep(s) {
+		s.Context.Sampled = false
+	}
+}
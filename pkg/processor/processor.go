@@ -0,0 +1,18 @@
+package processor
+
+import (
+	"context"
+	"tracer/pkg/span"
+)
+
+// SpanProcessor hooks into the life cycle of every span created by a Tracer.
+// Processors are called in the order they were registered.
+type SpanProcessor interface {
+	// OnStart is called right after a span is created, before it is returned to the caller.
+	// ctx is the context passed to StartSpanFromContext, or context.Background() for StartSpan,
+	// so request-scoped values such as a tenant ID can be copied into the span.
+	OnStart(ctx context.Context, s *span.Span)
+	// OnEnd is called when a sampled span finishes. A processor may change the model;
+	// clearing Context.Sampled drops the span so the reporter will not send it.
+	OnEnd(s *span.ToModel)
+}
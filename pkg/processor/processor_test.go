@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tracer/internal/testutil"
+	"tracer/pkg/config"
+	"tracer/pkg/span"
+)
+
+func TestFilterProcessor(t *testing.T) {
+	p := NewFilterProcessor(func(s *span.ToModel) bool {
+		return s.Operation != "health"
+	})
+
+	tests := []struct {
+		operation string
+		sampled   bool
+		want      bool
+	}{
+		{"work", true, true},
+		{"health", true, false},
+		// a span the sampler dropped is never turned back on
+		{"work", false, false},
+	}
+
+	for _, tt := range tests {
+		m := &span.ToModel{Operation: tt.operation, Context: span.SpanContext{Sampled: tt.sampled}}
+		p.OnEnd(m)
+		if m.Context.Sampled != tt.want {
+			t.Errorf("%s sampled=%v: Sampled = %v after OnEnd, want %v", tt.operation, tt.sampled, m.Context.Sampled, tt.want)
+		}
+	}
+}
+
+func TestRedactProcessor(t *testing.T) {
+	p := NewRedactProcessor("password", "authorization")
+
+	tags := []config.Tag{{Key: "password", Value: "hunter2"}, {Key: "user", Value: "bob"}}
+	fields := []config.Tag{{Key: "authorization", Value: "Bearer x"}, {Key: "event", Value: "login"}}
+	m := &span.ToModel{
+		Tags: tags,
+		Logs: []span.Log{{Timestamp: time.Now(), Fields: fields}},
+	}
+	p.OnEnd(m)
+
+	if got, _ := testutil.TagValue(m.Tags, "password"); got != Redacted {
+		t.Errorf("password tag = %v, want %s", got, Redacted)
+	}
+	if got, _ := testutil.TagValue(m.Tags, "user"); got != "bob" {
+		t.Errorf("user tag = %v, want bob", got)
+	}
+	if got, _ := testutil.TagValue(m.Logs[0].Fields, "authorization"); got != Redacted {
+		t.Errorf("authorization field = %v, want %s", got, Redacted)
+	}
+	if got, _ := testutil.TagValue(m.Logs[0].Fields, "event"); got != "login" {
+		t.Errorf("event field = %v, want login", got)
+	}
+
+	// the span's own tags and fields are copied, not rewritten in place
+	if tags[0].Value != "hunter2" || fields[0].Value != "Bearer x" {
+		t.Fatal("RedactProcessor modified the finished span's slices")
+	}
+}
+
+func TestTagAndBaggageProcessors(t *testing.T) {
+	s := &span.Span{Context: span.SpanContext{TraceID: "t", SpanID: "s", Sampled: true}}
+	s.SetBaggageItem("tenant", "acme")
+
+	NewTagProcessor(config.Tag{Key: "region", Value: "eu"}).OnStart(context.Background(), s)
+	NewBaggageProcessor("tenant", "missing").OnStart(context.Background(), s)
+
+	m := s.ToModel()
+	if got, _ := testutil.TagValue(m.Tags, "region"); got != "eu" {
+		t.Errorf("region tag = %v, want eu", got)
+	}
+	if got, _ := testutil.TagValue(m.Tags, "tenant"); got != "acme" {
+		t.Errorf("tenant tag = %v, want acme", got)
+	}
+	if got, _ := testutil.TagValue(m.Tags, "missing"); got != nil {
+		t.Errorf("missing tag = %v, want none", got)
+	}
+}
@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"context"
+	"tracer/pkg/config"
+	"tracer/pkg/span"
+)
+
+// Redacted replaces the value of redacted tags and log fields.
+const Redacted = "[REDACTED]"
+
+// RedactProcessor hides the values of sensitive tags and log fields before the span leaves the process.
+type RedactProcessor struct {
+	keys map[string]struct{}
+}
+
+// NewRedactProcessor creates a new RedactProcessor for the given keys.
+func NewRedactProcessor(keys ...string) *RedactProcessor {
+	p := &RedactProcessor{keys: make(map[string]struct{}, len(keys))}
+	for _, key := range keys {
+		p.keys[key] = struct{}{}
+	}
+
+	return p
+}
+
+func (p *RedactProcessor) OnStart(context.Context, *span.Span) {}
+
+func (p *RedactProcessor) OnEnd(s *span.ToModel) {
+	s.Tags = p.redact(s.Tags)
+
+	logs := make([]span.Log, len(s.Logs))
+	for i, log := range s.Logs {
+		logs[i] = span.Log{
+			Timestamp: log.Timestamp,
+			Fields:    p.redact(log.Fields),
+		}
+	}
+	s.Logs = logs
+}
+
+// redact returns a copy of tags so the finished span itself is left untouched.
+func (p *RedactProcessor) redact(tags []config.Tag) []config.Tag {
+	if len(tags) == 0 {
+		return tags
+	}
+
+	out := make([]config.Tag, len(tags))
+	for i, tag := range tags {
+		if _, ok := p.keys[tag.Key]; ok {
+			tag.Value = Redacted
+		}
+		out[i] = tag
+	}
+
+	return out
+}
@@ -0,0 +1,28 @@
+package processor
+
+import (
+	"context"
+	"tracer/pkg/reporter"
+	"tracer/pkg/span"
+)
+
+// ReporterProcessor batches finished spans and sends them to the agent through a Reporter.
+type ReporterProcessor struct {
+	reporter *reporter.Reporter
+}
+
+// NewReporterProcessor creates a new ReporterProcessor.
+func NewReporterProcessor(r *reporter.Reporter) *ReporterProcessor {
+	return &ReporterProcessor{reporter: r}
+}
+
+func (p *ReporterProcessor) OnStart(context.Context, *span.Span) {}
+
+// OnEnd stores the span in the reporter batch unless an earlier processor dropped it.
+func (p *ReporterProcessor) OnEnd(s *span.ToModel) {
+	if !s.Context.Sampled {
+		return
+	}
+
+	p.reporter.Store(*s)
+}
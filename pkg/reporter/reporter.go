@@ -1,10 +1,13 @@
 package reporter
 
 import (
-	"fmt"
+	"log"
 	"net"
+	"sync"
 	"time"
 	"tracer/pkg/config"
+	"tracer/pkg/metrics"
+	"tracer/pkg/model"
 	"tracer/pkg/span"
 	"tracer/pkg/transport"
 )
@@ -16,11 +19,19 @@ type Reporter struct {
 	timer    *time.Timer
 	duration time.Duration
 	fullChan chan struct{}
+	metrics  *metrics.TracerMetrics
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
 }
 
-func NewReporter(conf *config.Configuration, tags ...config.Tag) (*Reporter, error) {
+// NewReporter creates a Reporter that records its self-telemetry in m, the metrics of its tracer.
+// A nil m discards the measurements. process is sent with every batch, see NewBatch.
+func NewReporter(conf *config.Configuration, m *metrics.TracerMetrics, process *model.Process) (*Reporter, error) {
 	r := new(Reporter)
-	err := r.init(conf, tags...)
+	err := r.init(conf, m, process)
 	if err != nil {
 		return nil, err
 	}
@@ -28,7 +39,7 @@ func NewReporter(conf *config.Configuration, tags ...config.Tag) (*Reporter, err
 	return r, nil
 }
 
-func (r *Reporter) init(conf *config.Configuration, tags ...config.Tag) error {
+func (r *Reporter) init(conf *config.Configuration, m *metrics.TracerMetrics, process *model.Process) error {
 	addr, err := net.ResolveUDPAddr("udp", conf.Reporter.AgentAddr)
 	if err != nil {
 		return err
@@ -42,26 +53,37 @@ func (r *Reporter) init(conf *config.Configuration, tags ...config.Tag) error {
 
 	ch := make(chan struct{}, 1)
 	r.fullChan = ch
+	r.done = make(chan struct{})
 	r.duration = conf.Reporter.Duration
+	if m == nil {
+		m = metrics.NewTracerMetrics(nil)
+	}
+	r.metrics = m
 
-	r.batch = transport.NewBatch(conf, ch, tags...)
+	r.batch = transport.NewBatch(conf, ch, m, process)
 	r.timer = time.NewTimer(r.duration)
 
 	return nil
 }
 
 func (r *Reporter) Start() {
-	go r.Run()
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.Run()
+	}()
 }
 
+// Run sends the batch whenever it fills up or the duration passes, until the Reporter is closed.
 func (r *Reporter) Run() {
 	for {
 		select {
+		case <-r.done:
+			r.timer.Stop()
+			return
 		case <-r.timer.C:
-			if err := r.Send(); err != nil {
-				fmt.Println(err)
-				return
-			}
+			// 发送失败已经计入 ReporterErrors，丢掉这一批继续跑，不能让 Reporter 停掉
+			_ = r.Send()
 
 			r.timer.Reset(r.duration)
 		case <-r.fullChan:
@@ -69,10 +91,7 @@ func (r *Reporter) Run() {
 				<-r.timer.C
 			}
 
-			if err := r.Send(); err != nil {
-				fmt.Println(err)
-				return
-			}
+			_ = r.Send()
 
 			r.timer.Reset(r.duration)
 		}
@@ -81,25 +100,46 @@ func (r *Reporter) Run() {
 }
 
 func (r *Reporter) Send() error {
-	if r.batch.IsEmpty() {
+	start := time.Now()
+	// size 是 GetData 实际取走的 span 数，先查 Len 的话两次加锁之间还可能有新的 span 进来
+	data, size, err := r.batch.GetData()
+	if size == 0 {
 		return nil
 	}
+	defer func() {
+		r.metrics.ReporterSendLatency.Record(time.Since(start))
+	}()
 
-	data, err := r.batch.GetData()
 	if err != nil {
-		fmt.Println(err)
+		r.metrics.ReporterErrors.Inc(1)
+		log.Println(err)
 		return err
 	}
 
 	_, err = r.conn.Write(data)
 	if err != nil {
-		fmt.Println(err)
+		r.metrics.ReporterErrors.Inc(1)
+		r.metrics.SpansDropped.Inc(int64(size))
+		log.Println(err)
 		return err
 	}
 
+	r.metrics.ReporterSuccess.Inc(1)
 	return nil
 }
 
+// Close stops the loop started by Start, sends the spans still in the batch and closes the connection.
+// Spans stored afterwards are never sent.
+func (r *Reporter) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		r.wg.Wait()
+		_ = r.Send()
+		r.closeErr = r.conn.Close()
+	})
+	return r.closeErr
+}
+
 func (r *Reporter) Store(span span.ToModel) {
 	r.batch.Push(span)
 }
@@ -0,0 +1,96 @@
+package resource
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"tracer/pkg/config"
+)
+
+const (
+	ContainerIDTagKey      = "container.id"
+	K8sPodNameTagKey       = "k8s.pod.name"
+	K8sNamespaceNameTagKey = "k8s.namespace.name"
+	K8sNodeNameTagKey      = "k8s.node.name"
+)
+
+var (
+	// cgroup v1 和 systemd 风格的路径里都带着 64 位十六进制的容器 ID，
+	// 例如 /docker/<id>、/kubepods/.../<id>、docker-<id>.scope、cri-containerd-<id>.scope
+	containerIDPattern = regexp.MustCompile(`([0-9a-f]{64})(?:\.scope)?$`)
+	// cgroup v2 下 /proc/self/cgroup 只有 "0::/"，只能从 mountinfo 里的 /containers/<id>/ 找
+	mountContainerIDPattern = regexp.MustCompile(`/containers/([0-9a-f]{64})/`)
+)
+
+// ContainerDetector reports the container ID read from /proc/self/cgroup (or /proc/self/mountinfo).
+type ContainerDetector struct{}
+
+func (ContainerDetector) Name() string { return "container" }
+
+func (ContainerDetector) Detect() []config.Tag {
+	cgroup, _ := os.ReadFile("/proc/self/cgroup")
+	mountinfo, _ := os.ReadFile("/proc/self/mountinfo")
+	id := containerID(bytes.NewReader(cgroup), bytes.NewReader(mountinfo))
+	if id == "" {
+		return nil
+	}
+
+	return []config.Tag{{Key: ContainerIDTagKey, Value: id}}
+}
+
+// containerID finds the container ID in the contents of /proc/self/cgroup,
+// falling back to /proc/self/mountinfo. It returns "" outside a container.
+func containerID(cgroup, mountinfo io.Reader) string {
+	if id := scan(cgroup, containerIDPattern); id != "" {
+		return id
+	}
+	return scan(mountinfo, mountContainerIDPattern)
+}
+
+// scan returns the first submatch of pattern found in the lines of r.
+func scan(r io.Reader, pattern *regexp.Regexp) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if match := pattern.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1]
+		}
+	}
+
+	return ""
+}
+
+// KubernetesDetector reports the pod, namespace and node from the environment
+// variables that are usually injected with the downward API.
+type KubernetesDetector struct{}
+
+func (KubernetesDetector) Name() string { return "kubernetes" }
+
+func (KubernetesDetector) Detect() []config.Tag {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return nil
+	}
+
+	var tags []config.Tag
+	if pod := firstEnv("POD_NAME", "K8S_POD_NAME", "HOSTNAME"); pod != "" {
+		tags = append(tags, config.Tag{Key: K8sPodNameTagKey, Value: pod})
+	}
+	if namespace := firstEnv("POD_NAMESPACE", "K8S_NAMESPACE", "KUBERNETES_NAMESPACE"); namespace != "" {
+		tags = append(tags, config.Tag{Key: K8sNamespaceNameTagKey, Value: namespace})
+	}
+	if node := firstEnv("NODE_NAME", "K8S_NODE_NAME"); node != "" {
+		tags = append(tags, config.Tag{Key: K8sNodeNameTagKey, Value: node})
+	}
+
+	return tags
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package resource
+
+import (
+	"strings"
+	"testing"
+)
+
+const testContainerID = "8a2d1f3c9b4e5d6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f"
+
+func TestContainerID(t *testing.T) {
+	tests := []struct {
+		name      string
+		cgroup    string
+		mountinfo string
+		want      string
+	}{
+		{
+			name: "cgroup v1 docker",
+			cgroup: "12:memory:/docker/" + testContainerID + "\n" +
+				"11:cpu,cpuacct:/docker/" + testContainerID + "\n",
+			want: testContainerID,
+		},
+		{
+			name:   "cgroup v1 kubepods",
+			cgroup: "11:cpuset:/kubepods/besteffort/pod3c5e8a7d-1f2b-4c6d-9e0a-7b8c9d0e1f2a/" + testContainerID + "\n",
+			want:   testContainerID,
+		},
+		{
+			name: "systemd slice",
+			cgroup: "1:name=systemd:/kubepods.slice/kubepods-burstable.slice/" +
+				"kubepods-burstable-pod3c5e8a7d_1f2b_4c6d_9e0a_7b8c9d0e1f2a.slice/cri-containerd-" + testContainerID + ".scope\n",
+			want: testContainerID,
+		},
+		{
+			name:   "systemd docker scope",
+			cgroup: "0::/system.slice/docker-" + testContainerID + ".scope\n",
+			want:   testContainerID,
+		},
+		{
+			name:   "cgroup v2 mountinfo",
+			cgroup: "0::/\n",
+			mountinfo: "1203 1180 0:112 / / rw,relatime master:1 - overlay overlay rw\n" +
+				"1215 1203 8:1 /var/lib/docker/containers/" + testContainerID + "/resolv.conf /etc/resolv.conf rw,relatime - ext4 /dev/sda1 rw\n",
+			want: testContainerID,
+		},
+		{
+			name:      "no container",
+			cgroup:    "0::/user.slice/user-1000.slice/session-2.scope\n",
+			mountinfo: "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n",
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containerID(strings.NewReader(tt.cgroup), strings.NewReader(tt.mountinfo)); got != tt.want {
+				t.Fatalf("containerID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package resource
+
+import (
+	"net"
+	"os"
+	"tracer/pkg/config"
+)
+
+const (
+	HostnameTagKey = "host.name"
+	IPTagKey       = "host.ip"
+)
+
+// HostnameDetector reports the host name given by the kernel.
+type HostnameDetector struct{}
+
+func (HostnameDetector) Name() string { return "hostname" }
+
+func (HostnameDetector) Detect() []config.Tag {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return nil
+	}
+
+	return []config.Tag{{Key: HostnameTagKey, Value: hostname}}
+}
+
+// IPDetector reports the primary IPv4 address of the host.
+type IPDetector struct{}
+
+func (IPDetector) Name() string { return "ip" }
+
+func (IPDetector) Detect() []config.Tag {
+	ip := PrimaryIP()
+	if ip == "" {
+		return nil
+	}
+
+	return []config.Tag{{Key: IPTagKey, Value: ip}}
+}
+
+// PrimaryIP returns the first IPv4 address of an interface that is up and not a loopback.
+func PrimaryIP() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if ip4 := ipNet.IP.To4(); ip4 != nil {
+				return ip4.String()
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,63 @@
+package resource
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"tracer/pkg/config"
+)
+
+// Version is the version of the tracer SDK.
+const Version = "0.1.0"
+
+const (
+	PIDTagKey         = "process.pid"
+	ExecutableTagKey  = "process.executable.name"
+	GoVersionTagKey   = "process.runtime.version"
+	SDKLanguageTagKey = "tracer.sdk.language"
+	SDKVersionTagKey  = "tracer.sdk.version"
+)
+
+// PIDDetector reports the process ID.
+type PIDDetector struct{}
+
+func (PIDDetector) Name() string { return "pid" }
+
+func (PIDDetector) Detect() []config.Tag {
+	return []config.Tag{{Key: PIDTagKey, Value: os.Getpid()}}
+}
+
+// ExecutableDetector reports the file name of the running binary.
+type ExecutableDetector struct{}
+
+func (ExecutableDetector) Name() string { return "executable" }
+
+func (ExecutableDetector) Detect() []config.Tag {
+	path, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+
+	return []config.Tag{{Key: ExecutableTagKey, Value: filepath.Base(path)}}
+}
+
+// GoVersionDetector reports the Go runtime version.
+type GoVersionDetector struct{}
+
+func (GoVersionDetector) Name() string { return "go_version" }
+
+func (GoVersionDetector) Detect() []config.Tag {
+	return []config.Tag{{Key: GoVersionTagKey, Value: runtime.Version()}}
+}
+
+// SDKVersionDetector reports the language and version of this SDK.
+type SDKVersionDetector struct{}
+
+func (SDKVersionDetector) Name() string { return "sdk_version" }
+
+func (SDKVersionDetector) Detect() []config.Tag {
+	return []config.Tag{
+		{Key: SDKLanguageTagKey, Value: "go"},
+		{Key: SDKVersionTagKey, Value: Version},
+	}
+}
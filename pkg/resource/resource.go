@@ -0,0 +1,69 @@
+package resource
+
+import "tracer/pkg/config"
+
+// DisableAll turns every detector off when listed in ResourceConfig.Disabled.
+const DisableAll = "all"
+
+// Detector finds tags describing the process or the host it runs on.
+type Detector interface {
+	Name() string
+	Detect() []config.Tag
+}
+
+// Detectors returns every built-in detector.
+func Detectors() []Detector {
+	return []Detector{
+		HostnameDetector{},
+		IPDetector{},
+		PIDDetector{},
+		ExecutableDetector{},
+		GoVersionDetector{},
+		SDKVersionDetector{},
+		ContainerDetector{},
+		KubernetesDetector{},
+	}
+}
+
+// Detect runs the detectors enabled by the configuration and returns their tags.
+func Detect(conf *config.ResourceConfig) []config.Tag {
+	disabled := make(map[string]struct{})
+	if conf != nil {
+		for _, name := range conf.Disabled {
+			disabled[name] = struct{}{}
+		}
+	}
+	if _, ok := disabled[DisableAll]; ok {
+		return nil
+	}
+
+	var tags []config.Tag
+	for _, d := range Detectors() {
+		if _, ok := disabled[d.Name()]; ok {
+			continue
+		}
+		tags = append(tags, d.Detect()...)
+	}
+
+	return tags
+}
+
+// Merge combines tag lists. When a key appears more than once the last value wins,
+// so detected tags should come first and user tags last.
+func Merge(lists ...[]config.Tag) []config.Tag {
+	index := make(map[string]int)
+	var out []config.Tag
+
+	for _, list := range lists {
+		for _, tag := range list {
+			if i, ok := index[tag.Key]; ok {
+				out[i].Value = tag.Value
+				continue
+			}
+			index[tag.Key] = len(out)
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
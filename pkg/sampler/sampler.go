@@ -5,6 +5,7 @@ import "tracer/pkg/config"
 type Sampler interface {
 	IsSample(traceID, operation string) bool
 	GetTags() []config.Tag
+	GetType() string
 	init()
 }
 
@@ -35,3 +35,7 @@ func (s *SamplerConst) IsSample(traceID, operation string) bool {
 func (s *SamplerConst) GetTags() []config.Tag {
 	return s.Tags
 }
+
+func (s *SamplerConst) GetType() string {
+	return s.Type
+}
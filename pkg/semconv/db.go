@@ -0,0 +1,39 @@
+package semconv
+
+import "tracer/pkg/config"
+
+const (
+	DBSystemKey    = "db.system"
+	DBNameKey      = "db.name"
+	DBUserKey      = "db.user"
+	DBStatementKey = "db.statement"
+	DBOperationKey = "db.operation"
+	DBTableKey     = "db.sql.table"
+)
+
+// DBSystem is the database product, e.g. "mysql", "redis", "clickhouse".
+func DBSystem(system string) config.Tag {
+	return config.Tag{Key: DBSystemKey, Value: system}
+}
+
+func DBName(name string) config.Tag {
+	return config.Tag{Key: DBNameKey, Value: name}
+}
+
+func DBUser(user string) config.Tag {
+	return config.Tag{Key: DBUserKey, Value: user}
+}
+
+// DBStatement is the query text. Strip literal values before recording it if they can be sensitive.
+func DBStatement(statement string) config.Tag {
+	return config.Tag{Key: DBStatementKey, Value: statement}
+}
+
+// DBOperation is the command, e.g. "SELECT" or "HGET".
+func DBOperation(operation string) config.Tag {
+	return config.Tag{Key: DBOperationKey, Value: operation}
+}
+
+func DBTable(table string) config.Tag {
+	return config.Tag{Key: DBTableKey, Value: table}
+}
@@ -0,0 +1,28 @@
+package semconv
+
+import (
+	"fmt"
+	"tracer/pkg/config"
+)
+
+// ExceptionEvent is the event name of a log record describing an error.
+const ExceptionEvent = "exception"
+
+const (
+	ExceptionTypeKey       = "exception.type"
+	ExceptionMessageKey    = "exception.message"
+	ExceptionStacktraceKey = "exception.stacktrace"
+)
+
+// ExceptionType is the Go type of err, e.g. *net.OpError.
+func ExceptionType(err error) config.Tag {
+	return config.Tag{Key: ExceptionTypeKey, Value: fmt.Sprintf("%T", err)}
+}
+
+func ExceptionMessage(message string) config.Tag {
+	return config.Tag{Key: ExceptionMessageKey, Value: message}
+}
+
+func ExceptionStacktrace(stack string) config.Tag {
+	return config.Tag{Key: ExceptionStacktraceKey, Value: stack}
+}
@@ -0,0 +1,64 @@
+package semconv
+
+import "tracer/pkg/config"
+
+const (
+	HTTPMethodKey       = "http.method"
+	HTTPURLKey          = "http.url"
+	HTTPTargetKey       = "http.target"
+	HTTPRouteKey        = "http.route"
+	HTTPHostKey         = "http.host"
+	HTTPSchemeKey       = "http.scheme"
+	HTTPStatusCodeKey   = "http.status_code"
+	HTTPUserAgentKey    = "http.user_agent"
+	HTTPRequestSizeKey  = "http.request_content_length"
+	HTTPResponseSizeKey = "http.response_content_length"
+	HTTPClientIPKey     = "http.client_ip"
+)
+
+func HTTPMethod(method string) config.Tag {
+	return config.Tag{Key: HTTPMethodKey, Value: method}
+}
+
+// HTTPURL is the full request URL, e.g. https://example.com/user/123?x=1.
+func HTTPURL(url string) config.Tag {
+	return config.Tag{Key: HTTPURLKey, Value: url}
+}
+
+// HTTPTarget is the path and query of the request, e.g. /user/123?x=1.
+func HTTPTarget(target string) config.Tag {
+	return config.Tag{Key: HTTPTargetKey, Value: target}
+}
+
+// HTTPRoute is the matched route template, e.g. /user/:id.
+func HTTPRoute(route string) config.Tag {
+	return config.Tag{Key: HTTPRouteKey, Value: route}
+}
+
+func HTTPHost(host string) config.Tag {
+	return config.Tag{Key: HTTPHostKey, Value: host}
+}
+
+func HTTPScheme(scheme string) config.Tag {
+	return config.Tag{Key: HTTPSchemeKey, Value: scheme}
+}
+
+func HTTPStatusCode(code int) config.Tag {
+	return config.Tag{Key: HTTPStatusCodeKey, Value: code}
+}
+
+func HTTPUserAgent(userAgent string) config.Tag {
+	return config.Tag{Key: HTTPUserAgentKey, Value: userAgent}
+}
+
+func HTTPRequestSize(size int64) config.Tag {
+	return config.Tag{Key: HTTPRequestSizeKey, Value: size}
+}
+
+func HTTPResponseSize(size int64) config.Tag {
+	return config.Tag{Key: HTTPResponseSizeKey, Value: size}
+}
+
+func HTTPClientIP(ip string) config.Tag {
+	return config.Tag{Key: HTTPClientIPKey, Value: ip}
+}
@@ -0,0 +1,79 @@
+package semconv
+
+import "strings"
+
+// legacyKeys maps namespaced keys of older conventions (OpenTracing, Zipkin, early OpenTelemetry
+// drafts) forward to the canonical key. Generic words such as "method" or "sql" are left alone:
+// they are as likely to be an application's own tag. Keys of the current OpenTelemetry
+// conventions are not listed either, since mapping them onto older names would go backwards.
+var legacyKeys = map[string]string{
+	// http
+	"http.verb":   HTTPMethodKey,
+	"http.path":   HTTPTargetKey,
+	"http.uri":    HTTPURLKey,
+	"http.status": HTTPStatusCodeKey,
+	"http.code":   HTTPStatusCodeKey,
+
+	// rpc
+	"grpc.method":      RPCMethodKey,
+	"grpc.service":     RPCServiceKey,
+	"grpc.code":        RPCGRPCStatusCodeKey,
+	"grpc.status_code": RPCGRPCStatusCodeKey,
+
+	// db
+	"db.type":  DBSystemKey,
+	"db.query": DBStatementKey,
+	"db.sql":   DBStatementKey,
+	"db.table": DBTableKey,
+
+	// messaging
+	"message_bus.destination": MessagingDestinationKey,
+	"kafka.topic":             MessagingDestinationKey,
+	"kafka.partition":         MessagingKafkaPartitionKey,
+	"kafka.offset":            MessagingKafkaOffsetKey,
+
+	// network
+	"peer.hostname": NetPeerNameKey,
+	"peer.host":     NetPeerNameKey,
+	"peer.ipv4":     NetPeerIPKey,
+	"peer.ipv6":     NetPeerIPKey,
+	"peer.port":     NetPeerPortKey,
+
+	// exception (log fields)
+	"error.kind":    ExceptionTypeKey,
+	"error.message": ExceptionMessageKey,
+	"error.object":  ExceptionMessageKey,
+	"error.stack":   ExceptionStacktraceKey,
+}
+
+// spanKindValues maps legacy span.kind values to the canonical ones.
+var spanKindValues = map[string]string{
+	"client":     SpanKindClient,
+	"server":     SpanKindServer,
+	"producer":   SpanKindProducer,
+	"consumer":   SpanKindConsumer,
+	"internal":   SpanKindInternal,
+	"rpc_client": SpanKindClient,
+	"rpc_server": SpanKindServer,
+}
+
+// CanonicalKey returns the canonical spelling of key and true, or key itself and false when it is not a known legacy spelling.
+func CanonicalKey(key string) (string, bool) {
+	canonical, ok := legacyKeys[key]
+	if !ok {
+		return key, false
+	}
+
+	return canonical, true
+}
+
+// CanonicalSpanKind returns the canonical span.kind value, e.g. "CLIENT" and "rpc_client" become "client".
+// Unknown values are returned lowercased.
+func CanonicalSpanKind(kind string) string {
+	kind = strings.ToLower(kind)
+	if canonical, ok := spanKindValues[kind]; ok {
+		return canonical
+	}
+
+	return kind
+}
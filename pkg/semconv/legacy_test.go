@@ -0,0 +1,84 @@
+package semconv
+
+import "testing"
+
+func TestCanonicalKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		want   string
+		mapped bool
+	}{
+		{"http.verb", "http.method", true},
+		{"http.path", "http.target", true},
+		{"http.uri", "http.url", true},
+		{"http.status", "http.status_code", true},
+		{"http.code", "http.status_code", true},
+		{"grpc.method", "rpc.method", true},
+		{"grpc.service", "rpc.service", true},
+		{"grpc.code", "rpc.grpc.status_code", true},
+		{"grpc.status_code", "rpc.grpc.status_code", true},
+		{"db.type", "db.system", true},
+		{"db.query", "db.statement", true},
+		{"db.sql", "db.statement", true},
+		{"db.table", "db.sql.table", true},
+		{"message_bus.destination", "messaging.destination", true},
+		{"kafka.topic", "messaging.destination", true},
+		{"kafka.partition", "messaging.kafka.partition", true},
+		{"kafka.offset", "messaging.kafka.offset", true},
+		{"peer.hostname", "net.peer.name", true},
+		{"peer.host", "net.peer.name", true},
+		{"peer.ipv4", "net.peer.ip", true},
+		{"peer.ipv6", "net.peer.ip", true},
+		{"peer.port", "net.peer.port", true},
+		{"error.kind", "exception.type", true},
+		{"error.message", "exception.message", true},
+		{"error.object", "exception.message", true},
+		{"error.stack", "exception.stacktrace", true},
+
+		// canonical keys stay as they are
+		{"http.method", "http.method", false},
+		{"db.statement", "db.statement", false},
+		{"exception.type", "exception.type", false},
+		// current OpenTelemetry names are not mapped backwards
+		{"http.request.method", "http.request.method", false},
+		{"http.response.status_code", "http.response.status_code", false},
+		{"server.address", "server.address", false},
+		// generic words are likely the application's own tags
+		{"method", "method", false},
+		{"sql", "sql", false},
+		{"status", "status", false},
+		{"error", "error", false},
+		// matching is case-sensitive
+		{"HTTP.PATH", "HTTP.PATH", false},
+	}
+
+	for _, tt := range tests {
+		got, mapped := CanonicalKey(tt.key)
+		if got != tt.want || mapped != tt.mapped {
+			t.Errorf("CanonicalKey(%q) = %q, %v, want %q, %v", tt.key, got, mapped, tt.want, tt.mapped)
+		}
+	}
+}
+
+func TestCanonicalSpanKind(t *testing.T) {
+	tests := []struct {
+		kind string
+		want string
+	}{
+		{"client", SpanKindClient},
+		{"CLIENT", SpanKindClient},
+		{"Server", SpanKindServer},
+		{"rpc_client", SpanKindClient},
+		{"rpc_server", SpanKindServer},
+		{"PRODUCER", SpanKindProducer},
+		{"consumer", SpanKindConsumer},
+		{"internal", SpanKindInternal},
+		{"Custom", "custom"},
+	}
+
+	for _, tt := range tests {
+		if got := CanonicalSpanKind(tt.kind); got != tt.want {
+			t.Errorf("CanonicalSpanKind(%q) = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+}
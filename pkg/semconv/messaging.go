@@ -0,0 +1,50 @@
+package semconv
+
+import "tracer/pkg/config"
+
+const (
+	MessagingSystemKey         = "messaging.system"
+	MessagingDestinationKey    = "messaging.destination"
+	MessagingOperationKey      = "messaging.operation"
+	MessagingMessageIDKey      = "messaging.message_id"
+	MessagingKafkaPartitionKey = "messaging.kafka.partition"
+	MessagingKafkaOffsetKey    = "messaging.kafka.offset"
+	MessagingKafkaGroupKey     = "messaging.kafka.consumer_group"
+)
+
+// Values of messaging.operation.
+const (
+	MessagingOperationPublish = "publish"
+	MessagingOperationReceive = "receive"
+	MessagingOperationProcess = "process"
+)
+
+// MessagingSystem is the broker, e.g. "kafka".
+func MessagingSystem(system string) config.Tag {
+	return config.Tag{Key: MessagingSystemKey, Value: system}
+}
+
+// MessagingDestination is the topic or queue name.
+func MessagingDestination(destination string) config.Tag {
+	return config.Tag{Key: MessagingDestinationKey, Value: destination}
+}
+
+func MessagingOperation(operation string) config.Tag {
+	return config.Tag{Key: MessagingOperationKey, Value: operation}
+}
+
+func MessagingMessageID(id string) config.Tag {
+	return config.Tag{Key: MessagingMessageIDKey, Value: id}
+}
+
+func MessagingKafkaPartition(partition int32) config.Tag {
+	return config.Tag{Key: MessagingKafkaPartitionKey, Value: partition}
+}
+
+func MessagingKafkaOffset(offset int64) config.Tag {
+	return config.Tag{Key: MessagingKafkaOffsetKey, Value: offset}
+}
+
+func MessagingKafkaGroup(group string) config.Tag {
+	return config.Tag{Key: MessagingKafkaGroupKey, Value: group}
+}
@@ -0,0 +1,82 @@
+package semconv
+
+import (
+	"strings"
+	"time"
+	"tracer/pkg/config"
+)
+
+const (
+	NetTransportKey = "net.transport"
+	NetPeerNameKey  = "net.peer.name"
+	NetPeerIPKey    = "net.peer.ip"
+	NetPeerPortKey  = "net.peer.port"
+	// NetPeerIPsKey lists every address a DNS lookup returned, comma separated.
+	NetPeerIPsKey = "net.peer.ips"
+	// NetPeerAddrKey is the dialed host:port.
+	NetPeerAddrKey = "net.peer.addr"
+	NetHostNameKey = "net.host.name"
+	NetHostIPKey   = "net.host.ip"
+	NetHostPortKey = "net.host.port"
+	// PeerServiceKey is the service name of the remote side, when the caller knows it.
+	PeerServiceKey = "peer.service"
+
+	// NetConnReusedKey is true when an HTTP request got a connection from the pool.
+	NetConnReusedKey   = "net.conn.reused"
+	NetConnWasIdleKey  = "net.conn.was_idle"
+	NetConnIdleTimeKey = "net.conn.idle_time"
+)
+
+// NetTransport is the network, e.g. "tcp" or "udp".
+func NetTransport(transport string) config.Tag {
+	return config.Tag{Key: NetTransportKey, Value: transport}
+}
+
+func NetPeerName(name string) config.Tag {
+	return config.Tag{Key: NetPeerNameKey, Value: name}
+}
+
+func NetPeerIP(ip string) config.Tag {
+	return config.Tag{Key: NetPeerIPKey, Value: ip}
+}
+
+func NetPeerPort(port int) config.Tag {
+	return config.Tag{Key: NetPeerPortKey, Value: port}
+}
+
+func NetPeerIPs(ips []string) config.Tag {
+	return config.Tag{Key: NetPeerIPsKey, Value: strings.Join(ips, ",")}
+}
+
+func NetPeerAddr(addr string) config.Tag {
+	return config.Tag{Key: NetPeerAddrKey, Value: addr}
+}
+
+func NetHostName(name string) config.Tag {
+	return config.Tag{Key: NetHostNameKey, Value: name}
+}
+
+func NetHostIP(ip string) config.Tag {
+	return config.Tag{Key: NetHostIPKey, Value: ip}
+}
+
+func NetHostPort(port int) config.Tag {
+	return config.Tag{Key: NetHostPortKey, Value: port}
+}
+
+func PeerService(service string) config.Tag {
+	return config.Tag{Key: PeerServiceKey, Value: service}
+}
+
+func NetConnReused(reused bool) config.Tag {
+	return config.Tag{Key: NetConnReusedKey, Value: reused}
+}
+
+func NetConnWasIdle(wasIdle bool) config.Tag {
+	return config.Tag{Key: NetConnWasIdleKey, Value: wasIdle}
+}
+
+// NetConnIdleTime is how long the connection sat in the pool, e.g. "1.5s".
+func NetConnIdleTime(d time.Duration) config.Tag {
+	return config.Tag{Key: NetConnIdleTimeKey, Value: d.String()}
+}
@@ -0,0 +1,29 @@
+package semconv
+
+import "tracer/pkg/config"
+
+const (
+	RPCSystemKey         = "rpc.system"
+	RPCServiceKey        = "rpc.service"
+	RPCMethodKey         = "rpc.method"
+	RPCGRPCStatusCodeKey = "rpc.grpc.status_code"
+)
+
+// RPCSystem is the RPC framework, e.g. "grpc".
+func RPCSystem(system string) config.Tag {
+	return config.Tag{Key: RPCSystemKey, Value: system}
+}
+
+// RPCService is the full service name, e.g. "tracer.Collector".
+func RPCService(service string) config.Tag {
+	return config.Tag{Key: RPCServiceKey, Value: service}
+}
+
+func RPCMethod(method string) config.Tag {
+	return config.Tag{Key: RPCMethodKey, Value: method}
+}
+
+// RPCGRPCStatusCode is the numeric gRPC status code, 0 for OK.
+func RPCGRPCStatusCode(code int) config.Tag {
+	return config.Tag{Key: RPCGRPCStatusCodeKey, Value: code}
+}
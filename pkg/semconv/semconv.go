@@ -0,0 +1,49 @@
+// Package semconv defines the canonical tag keys used by the tracer and typed constructors for them.
+// Use these instead of ad-hoc strings so the same attribute is always spelled the same way in storage.
+package semconv
+
+import "tracer/pkg/config"
+
+const (
+	SpanKindKey = "span.kind"
+	ErrorKey    = "error"
+	// EventKey names a log record on a span, e.g. "exception" or "cache_miss".
+	EventKey = "event"
+	// ServiceNameKey is the resource attribute that OpenTelemetry and Zipkin use for the service name.
+	ServiceNameKey = "service.name"
+)
+
+// Status and instrumentation scope of spans received from OpenTelemetry SDKs.
+const (
+	StatusCodeKey        = "otel.status_code"
+	StatusDescriptionKey = "otel.status_description"
+	ScopeNameKey         = "otel.scope.name"
+	ScopeVersionKey      = "otel.scope.version"
+)
+
+// Values of otel.status_code.
+const (
+	StatusCodeOK    = "OK"
+	StatusCodeError = "ERROR"
+)
+
+// Values of span.kind.
+const (
+	SpanKindClient   = "client"
+	SpanKindServer   = "server"
+	SpanKindProducer = "producer"
+	SpanKindConsumer = "consumer"
+	SpanKindInternal = "internal"
+)
+
+func SpanKind(kind string) config.Tag {
+	return config.Tag{Key: SpanKindKey, Value: kind}
+}
+
+func Error(isError bool) config.Tag {
+	return config.Tag{Key: ErrorKey, Value: isError}
+}
+
+func Event(name string) config.Tag {
+	return config.Tag{Key: EventKey, Value: name}
+}
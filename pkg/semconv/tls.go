@@ -0,0 +1,32 @@
+package semconv
+
+import (
+	"crypto/tls"
+	"tracer/pkg/config"
+)
+
+const (
+	TLSVersionKey    = "tls.version"
+	TLSCipherKey     = "tls.cipher"
+	TLSServerNameKey = "tls.server_name"
+	// TLSResumedKey is true when the handshake resumed an earlier session.
+	TLSResumedKey = "tls.resumed"
+)
+
+// TLSVersion is the protocol version by name, e.g. "TLS 1.3".
+func TLSVersion(version uint16) config.Tag {
+	return config.Tag{Key: TLSVersionKey, Value: tls.VersionName(version)}
+}
+
+// TLSCipher is the cipher suite by name, e.g. "TLS_AES_128_GCM_SHA256".
+func TLSCipher(suite uint16) config.Tag {
+	return config.Tag{Key: TLSCipherKey, Value: tls.CipherSuiteName(suite)}
+}
+
+func TLSServerName(name string) config.Tag {
+	return config.Tag{Key: TLSServerNameKey, Value: name}
+}
+
+func TLSResumed(resumed bool) config.Tag {
+	return config.Tag{Key: TLSResumedKey, Value: resumed}
+}
@@ -0,0 +1,11 @@
+package span
+
+import "time"
+
+// FinishOptions controls how a span is finished by FinishWithOptions.
+type FinishOptions struct {
+	// FinishTime is the end of the span. The zero value means time.Now().
+	FinishTime time.Time
+	// LogRecords are appended to the span logs; each one keeps its own timestamp.
+	LogRecords []Log
+}
@@ -0,0 +1,32 @@
+package span
+
+import (
+	"time"
+	"tracer/pkg/config"
+)
+
+// noopSpan has no state, so the single value shared by every caller cannot be changed.
+type noopSpan struct{}
+
+var _ Interface = noopSpan{}
+
+// NoopSpan returns a span that records nothing.
+// It is returned when there is no span to work with, so callers never get nil.
+// It has no fields: nothing a caller does to it is seen by another caller.
+func NoopSpan() Interface {
+	return noopSpan{}
+}
+
+func (noopSpan) IsNoop() bool                         { return true }
+func (noopSpan) Finish()                              {}
+func (noopSpan) FinishWithOptions(FinishOptions)      {}
+func (noopSpan) AddFinishHook(func())                 {}
+func (noopSpan) IsFinished() bool                     { return false }
+func (noopSpan) SetTag(string, interface{})           {}
+func (noopSpan) SetTags(...config.Tag)                {}
+func (noopSpan) SetBaggageItem(string, string)        {}
+func (noopSpan) GetBaggageItem(string) string         { return "" }
+func (noopSpan) SpanContext() SpanContext             { return SpanContext{} }
+func (noopSpan) LogFields(...config.Tag)              {}
+func (noopSpan) LogFieldsAt(time.Time, ...config.Tag) {}
+func (noopSpan) RecordError(error, ...config.Tag)     {}
@@ -1,12 +1,30 @@
 package span
 
+import "tracer/pkg/config"
+
 type Reference struct {
 	TraceID string
 	SpanID  string
 	RefType string
+	// Attributes describe the relationship, e.g. the partition and offset of a consumed message.
+	Attributes []config.Tag
 }
 
 const (
 	ChildOf    = "CHILD_OF"
-	FollowFrom = "FOLLOW_FROm"
+	FollowFrom = "FOLLOW_FROM"
+	// Link points to a related span, usually in another trace. It does not choose the trace or the parent.
+	Link = "LINK"
+
+	// legacyFollowFrom is how SDKs before FOLLOW_FROM was fixed spell it on the wire.
+	legacyFollowFrom = "FOLLOW_FROm"
 )
+
+// NormalizeRefType maps the legacy spelling of a reference type onto the current one,
+// so references sent by older SDKs are stored with a single value per relation.
+func NormalizeRefType(refType string) string {
+	if refType == legacyFollowFrom {
+		return FollowFrom
+	}
+	return refType
+}
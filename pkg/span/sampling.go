@@ -0,0 +1,65 @@
+package span
+
+import (
+	"strconv"
+	"tracer/pkg/config"
+)
+
+const (
+	// SamplingPriorityTagKey overrides the sampler: a value > 0 forces the span to be
+	// sampled and debug-flagged, 0 drops it. Pass it as a start tag: an unsampled span records
+	// nothing, so a priority set later only keeps what is recorded after it.
+	SamplingPriorityTagKey = "sampling.priority"
+	// DebugIDTagKey holds the correlation value of a debug request (the tracer-debug-id header).
+	DebugIDTagKey = "tracer.debug_id"
+)
+
+// SamplingPriority reads a sampling.priority value set as a number or a numeric string.
+func SamplingPriority(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int8:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint:
+		return int(v), true
+	case uint8:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case float32:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		p, err := strconv.Atoi(v)
+		return p, err == nil
+	}
+
+	return 0, false
+}
+
+// ApplySamplingPriority changes the sampling decision of sc according to the
+// sampling.priority tag, if one of the tags carries it.
+func ApplySamplingPriority(sc *SpanContext, tags []config.Tag) {
+	for _, tag := range tags {
+		if tag.Key != SamplingPriorityTagKey {
+			continue
+		}
+
+		if priority, ok := SamplingPriority(tag.Value); ok {
+			sc.Sampled = priority > 0
+			sc.Debug = priority > 0
+		}
+	}
+}
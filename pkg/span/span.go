@@ -1,12 +1,36 @@
 package span
 
 import (
+	"sync"
 	"time"
 	"tracer/pkg/config"
+	"tracer/pkg/semconv"
 )
 
+// Interface is the span API returned by tracers. It is implemented by *Span and by the
+// span returned from NoopSpan, so code written against it works with tracing disabled.
+type Interface interface {
+	// IsNoop reports whether the span records nothing.
+	IsNoop() bool
+	Finish()
+	FinishWithOptions(opts FinishOptions)
+	AddFinishHook(f func())
+	IsFinished() bool
+	SetTag(key string, value interface{})
+	SetTags(tags ...config.Tag)
+	SetBaggageItem(key, value string)
+	GetBaggageItem(key string) string
+	SpanContext() SpanContext
+	LogFields(fields ...config.Tag)
+	LogFieldsAt(ts time.Time, fields ...config.Tag)
+	RecordError(err error, fields ...config.Tag)
+}
+
+var _ Interface = (*Span)(nil)
+
 // Span represents a unit of work in a trace.
 // It contains metadata such as operation name, start time, duration, tags, and logs.
+// Its methods are safe for concurrent use; once the span is finished they leave it untouched.
 type Span struct {
 	Operation  string
 	Context    SpanContext
@@ -16,26 +40,114 @@ type Span struct {
 	ProcessID  string
 	References []Reference
 	OnFinish   func(toModel *ToModel)
-	Logs       []Log
+	// OnMutationAfterFinish is called, if set, each time a method tries to change a finished span.
+	OnMutationAfterFinish func()
+	Logs                  []Log
+
+	mu          sync.Mutex
+	finished    bool
+	finishHooks []func()
+}
+
+// IsNoop reports whether the span records nothing. It is always false for a *Span.
+func (s *Span) IsNoop() bool {
+	return false
 }
 
 // Finish marks the end of the span execution.
 // It calculates the duration and triggers the OnFinish callback if the span is sampled.
 func (s *Span) Finish() {
-	s.Duration = time.Since(s.StartTime)
+	s.FinishWithOptions(FinishOptions{})
+}
+
+// FinishWithOptions is like Finish but allows an explicit finish time and
+// bulk log records that carry their own timestamps.
+// Only the first call finishes the span; later calls are ignored.
+func (s *Span) FinishWithOptions(opts FinishOptions) {
+	finishTime := opts.FinishTime
+	if finishTime.IsZero() {
+		finishTime = time.Now()
+	}
+
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		s.mutationAfterFinish()
+		return
+	}
+	s.finished = true
+	hooks := s.finishHooks
+	s.finishHooks = nil
+
+	s.Duration = finishTime.Sub(s.StartTime)
 	if !s.Context.Sampled {
+		s.mu.Unlock()
+		runHooks(hooks)
 		return
 	}
 
-	s.OnFinish(s.ToModel())
+	s.Logs = append(s.Logs, opts.LogRecords...)
+	model := s.toModel()
+	s.mu.Unlock()
+
+	// 回调在锁外执行，processor 里慢一点也不会卡住别的 goroutine
+	runHooks(hooks)
+	s.OnFinish(model)
+}
+
+// AddFinishHook registers f to run on the finishing goroutine when the span finishes,
+// sampled or not. Hooks run in reverse order of registration, like defer.
+func (s *Span) AddFinishHook(f func()) {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		s.mutationAfterFinish()
+		return
+	}
+	defer s.mu.Unlock()
+
+	s.finishHooks = append(s.finishHooks, f)
+}
+
+func runHooks(hooks []func()) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// IsFinished reports whether Finish has been called.
+func (s *Span) IsFinished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.finished
 }
 
 // SetTag adds or updates a tag on the span.
 // If the tag with the given key already exists, its value is updated.
+// Unsampled spans are never reported, so their tags are not recorded.
+// Set sampling.priority at StartSpan (tracer.WithTag) to sample a span from its start: setting it here
+// still turns sampling on or off, but tags and logs dropped before the call are not recovered.
 func (s *Span) SetTag(key string, value interface{}) {
-	for _, tag := range s.Tags {
-		if tag.Key == key {
-			tag.Value = value
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		s.mutationAfterFinish()
+		return
+	}
+	defer s.mu.Unlock()
+
+	if key == SamplingPriorityTagKey {
+		ApplySamplingPriority(&s.Context, []config.Tag{{Key: key, Value: value}})
+	}
+
+	if !s.Context.Sampled {
+		return
+	}
+
+	for i := range s.Tags {
+		if s.Tags[i].Key == key {
+			s.Tags[i].Value = value
 			return
 		}
 	}
@@ -46,27 +158,98 @@ func (s *Span) SetTag(key string, value interface{}) {
 	})
 }
 
+// SetTags sets each tag as SetTag does, e.g. s.SetTags(semconv.HTTPStatusCode(200)).
+func (s *Span) SetTags(tags ...config.Tag) {
+	for _, tag := range tags {
+		s.SetTag(tag.Key, tag.Value)
+	}
+}
+
 // SetBaggageItem sets a key:value pair on the span context that propagates to child spans.
 func (s *Span) SetBaggageItem(key, value string) {
-	s.Context.Baggage[key] = value
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		s.mutationAfterFinish()
+		return
+	}
+	defer s.mu.Unlock()
+
+	// copy-on-write：baggage map 会被子 span 和已经取走的 SpanContext 共享，不能原地修改
+	baggage := make(map[string]string, len(s.Context.Baggage)+1)
+	for k, v := range s.Context.Baggage {
+		baggage[k] = v
+	}
+	baggage[key] = value
+	s.Context.Baggage = baggage
 }
 
 // GetBaggageItem retrieves the value of a baggage item from the span context.
 func (s *Span) GetBaggageItem(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return s.Context.Baggage[key]
 }
 
+// SpanContext returns a snapshot of the span context that is safe to pass to other goroutines.
+func (s *Span) SpanContext() SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Context
+}
+
 func (s *Span) LogFields(fields ...config.Tag) {
-	var log Log
-	for _, field := range fields {
-		log.Fields = append(log.Fields, field)
+	s.LogFieldsAt(time.Now(), fields...)
+}
+
+// LogFieldsAt is like LogFields but records the log with the given timestamp.
+func (s *Span) LogFieldsAt(ts time.Time, fields ...config.Tag) {
+	log := Log{
+		Timestamp: ts,
+		Fields:    append([]config.Tag(nil), fields...),
+	}
+
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		s.mutationAfterFinish()
+		return
+	}
+	defer s.mu.Unlock()
+
+	if !s.Context.Sampled {
+		return
 	}
 
-	log.Timestamp = time.Now()
 	s.Logs = append(s.Logs, log)
 }
 
+// RecordError marks the span as failed and logs the error as an exception event.
+func (s *Span) RecordError(err error, fields ...config.Tag) {
+	if err == nil {
+		return
+	}
+
+	s.SetTag(semconv.ErrorKey, true)
+	s.LogFields(append([]config.Tag{
+		semconv.Event(semconv.ExceptionEvent),
+		semconv.ExceptionType(err),
+		semconv.ExceptionMessage(err.Error()),
+	}, fields...)...)
+}
+
+// ToModel returns a snapshot of the span.
 func (s *Span) ToModel() *ToModel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.toModel()
+}
+
+// toModel must be called with s.mu held.
+func (s *Span) toModel() *ToModel {
 	return &ToModel{
 		Operation:  s.Operation,
 		Context:    s.Context,
@@ -78,3 +261,9 @@ func (s *Span) ToModel() *ToModel {
 		Logs:       s.Logs,
 	}
 }
+
+func (s *Span) mutationAfterFinish() {
+	if s.OnMutationAfterFinish != nil {
+		s.OnMutationAfterFinish()
+	}
+}
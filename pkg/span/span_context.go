@@ -6,6 +6,10 @@ type SpanContext struct {
 	ParentID string
 	Baggage  map[string]string
 	Sampled  bool
+	// Debug marks a context that must be sampled and kept by the tail sampler.
+	Debug bool
+	// DebugID is the correlation value sent in the tracer-debug-id header, if any.
+	DebugID string
 }
 
 func NewSpanContext() SpanContext {
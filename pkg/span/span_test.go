@@ -0,0 +1,93 @@
+package span
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tracer/pkg/config"
+)
+
+func newTestSpan(onFinish func(*ToModel)) *Span {
+	return &Span{
+		Operation: "test",
+		Context: SpanContext{
+			TraceID: "trace",
+			SpanID:  "span",
+			Sampled: true,
+		},
+		StartTime: time.Now(),
+		OnFinish:  onFinish,
+	}
+}
+
+// Run with -race: every method may be called from several goroutines while another one finishes the span.
+func TestSpanConcurrentUse(t *testing.T) {
+	var reported atomic.Int32
+	s := newTestSpan(func(*ToModel) { reported.Add(1) })
+
+	const goroutines = 8
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.SetTag("key"+strconv.Itoa(j%10), i)
+				s.LogFields(config.Tag{Key: "i", Value: i})
+				s.SetBaggageItem("b"+strconv.Itoa(i), "v")
+				_ = s.GetBaggageItem("b0")
+				_ = s.SpanContext()
+				if j == 50 {
+					s.Finish()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := reported.Load(); got != 1 {
+		t.Fatalf("OnFinish called %d times, want 1", got)
+	}
+}
+
+func TestSetTagOverwrites(t *testing.T) {
+	s := newTestSpan(func(*ToModel) {})
+
+	s.SetTag("k", 1)
+	s.SetTag("other", true)
+	s.SetTag("k", 2)
+
+	var values []interface{}
+	for _, tag := range s.ToModel().Tags {
+		if tag.Key == "k" {
+			values = append(values, tag.Value)
+		}
+	}
+	if len(values) != 1 || values[0] != 2 {
+		t.Fatalf("tag k = %v, want one entry with value 2", values)
+	}
+}
+
+func TestFinishReportsOnce(t *testing.T) {
+	var reported, mutations int
+	s := newTestSpan(func(*ToModel) { reported++ })
+	s.OnMutationAfterFinish = func() { mutations++ }
+
+	s.Finish()
+	s.Finish()
+	s.SetTag("late", 1)
+
+	if reported != 1 {
+		t.Fatalf("OnFinish called %d times, want 1", reported)
+	}
+	if mutations != 2 {
+		t.Fatalf("OnMutationAfterFinish called %d times, want 2", mutations)
+	}
+	for _, tag := range s.ToModel().Tags {
+		if tag.Key == "late" {
+			t.Fatal("tag set after Finish was recorded")
+		}
+	}
+}
@@ -26,7 +26,10 @@ func (c *HttpCarrier) Get(key string) interface{} {
 
 func (c *HttpCarrier) Foreach(f func(key string, value interface{})) {
 	for k, v := range c.Header {
-		f(k, v)
+		if len(v) == 0 {
+			continue
+		}
+		f(k, v[0])
 	}
 }
 
@@ -39,11 +42,18 @@ func (c *GRPCCarrier) Set(key string, value interface{}) {
 }
 
 func (c *GRPCCarrier) Get(key string) interface{} {
-	return c.MD[key]
+	values := c.MD.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
 }
 
 func (c *GRPCCarrier) Foreach(f func(key string, value interface{})) {
 	for k, v := range c.MD {
+		if len(v) == 0 {
+			continue
+		}
 		f(k, v[0])
 	}
 }
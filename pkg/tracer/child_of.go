@@ -7,14 +7,24 @@ type ChildOfOption struct {
 }
 
 func (o *ChildOfOption) Apply(s *StartSpanOption) {
-	s.TracerID = o.ctx.TraceID
-	s.References = append(s.References, span.Reference{
-		RefType: span.ChildOf,
-		TraceID: o.ctx.TraceID,
-		SpanID:  o.ctx.SpanID,
-	})
+	// 只有 debug 头、没有 trace 的 context 不产生引用，只带上 debug 标记和 baggage
+	if o.ctx.TraceID != "" {
+		s.TracerID = o.ctx.TraceID
+		s.References = append(s.References, span.Reference{
+			RefType: span.ChildOf,
+			TraceID: o.ctx.TraceID,
+			SpanID:  o.ctx.SpanID,
+		})
+	}
 
-	s.Baggage = o.ctx.Baggage
+	// 没有 baggage 的 context 不覆盖前面 option 带来的 baggage
+	if len(o.ctx.Baggage) != 0 {
+		s.Baggage = o.ctx.Baggage
+	}
+	if o.ctx.Debug {
+		s.Debug = true
+		s.DebugID = o.ctx.DebugID
+	}
 }
 
 func ChildOf(ctx span.SpanContext) *ChildOfOption {
@@ -0,0 +1,34 @@
+package tracer
+
+import (
+	"context"
+	"tracer/pkg/span"
+)
+
+type SpanKeyType struct {
+}
+
+var spanKey = SpanKeyType{}
+
+// SpanFromContext returns the span stored in ctx.
+// It never returns nil: without a span it returns span.NoopSpan().
+func SpanFromContext(ctx context.Context) span.Interface {
+	if ctx == nil {
+		return span.NoopSpan()
+	}
+
+	s, ok := ctx.Value(spanKey).(span.Interface)
+	if !ok || s == nil {
+		return span.NoopSpan()
+	}
+	if concrete, isSpan := s.(*span.Span); isSpan && concrete == nil {
+		return span.NoopSpan()
+	}
+
+	return s
+}
+
+// ContextWithSpan returns a copy of ctx that carries the span.
+func ContextWithSpan(ctx context.Context, s span.Interface) context.Context {
+	return context.WithValue(ctx, spanKey, s)
+}
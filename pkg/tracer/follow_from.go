@@ -1,22 +1,41 @@
 package tracer
 
-import "tracer/pkg/span"
+import (
+	"tracer/pkg/config"
+	"tracer/pkg/span"
+)
 
 type FollowFromOption struct {
-	ctx span.SpanContext
+	ctx        span.SpanContext
+	attributes []config.Tag
 }
 
 func (o *FollowFromOption) Apply(s *StartSpanOption) {
-	s.TracerID = o.ctx.TraceID
-	s.References = append(s.References, span.Reference{
-		RefType: span.FollowFrom,
-		TraceID: o.ctx.TraceID,
-		SpanID:  o.ctx.SpanID,
-	})
+	// 只有 debug 头、没有 trace 的 context 不产生引用，只带上 debug 标记和 baggage
+	if o.ctx.TraceID != "" {
+		// 多个 FollowFrom 时沿用第一个的 trace，ChildOf 总是优先
+		if s.TracerID == "" {
+			s.TracerID = o.ctx.TraceID
+		}
+		s.References = append(s.References, span.Reference{
+			RefType:    span.FollowFrom,
+			TraceID:    o.ctx.TraceID,
+			SpanID:     o.ctx.SpanID,
+			Attributes: o.attributes,
+		})
+	}
 
-	s.Baggage = o.ctx.Baggage
+	if s.Baggage == nil {
+		s.Baggage = o.ctx.Baggage
+	}
+	if o.ctx.Debug {
+		s.Debug = true
+		s.DebugID = o.ctx.DebugID
+	}
 }
 
-func FollowFrom(ctx span.SpanContext) *FollowFromOption {
-	return &FollowFromOption{ctx: ctx}
+// FollowFrom references a span that caused this one but does not wait for it.
+// Attributes describe the relationship and are stored with the reference.
+func FollowFrom(ctx span.SpanContext, attributes ...config.Tag) *FollowFromOption {
+	return &FollowFromOption{ctx: ctx, attributes: attributes}
 }
@@ -0,0 +1,39 @@
+package tracer
+
+import (
+	"context"
+	"sync/atomic"
+	"tracer/pkg/span"
+)
+
+// globalHolder keeps atomic.Value happy: it requires the same concrete type on every Store.
+type globalHolder struct {
+	tracer Interface
+}
+
+var globalTracer atomic.Value
+
+func init() {
+	globalTracer.Store(globalHolder{tracer: NoopTracer{}})
+}
+
+// SetGlobalTracer registers the tracer used by the package-level helpers,
+// so libraries can create spans without having a Tracer injected. Nil restores the NoopTracer.
+func SetGlobalTracer(t Interface) {
+	if t == nil {
+		t = NoopTracer{}
+	}
+
+	globalTracer.Store(globalHolder{tracer: t})
+}
+
+// GlobalTracer returns the tracer registered with SetGlobalTracer.
+// It is a NoopTracer until one is set, so it is never nil.
+func GlobalTracer() Interface {
+	return globalTracer.Load().(globalHolder).tracer
+}
+
+// StartSpanFromContext starts a span with the global tracer.
+func StartSpanFromContext(ctx context.Context, operation string, options ...Option) (span.Interface, context.Context) {
+	return GlobalTracer().StartSpanFromContext(ctx, operation, options...)
+}
@@ -0,0 +1,157 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+// activeSpans holds the spans of goroutines started by Go and GoDetached that have not returned yet.
+var activeSpans sync.Map // span.Interface -> struct{}
+
+// UnfinishedSpans returns the spans of goroutines started by Go, GoDetached or WorkItem.Run
+// that are still running. A test can check it is empty to catch leaked goroutines.
+func UnfinishedSpans() []span.Interface {
+	var spans []span.Interface
+	activeSpans.Range(func(key, _ interface{}) bool {
+		spans = append(spans, key.(span.Interface))
+		return true
+	})
+
+	return spans
+}
+
+// Go runs fn in a new goroutine inside a child span of the span in ctx.
+// A returned error or a panic is recorded on the span; the panic does not crash the process.
+func (t *Tracer) Go(ctx context.Context, operation string, fn func(ctx context.Context) error, options ...Option) {
+	goWithTracer(t, ctx, operation, fn, options...)
+}
+
+// GoDetached runs fire-and-forget work in a new goroutine. The span follows from the span
+// in ctx instead of being its child, and the work is not cancelled with ctx.
+func (t *Tracer) GoDetached(ctx context.Context, operation string, fn func(ctx context.Context) error, options ...Option) {
+	goDetachedWithTracer(t, ctx, operation, fn, options...)
+}
+
+// Go is Tracer.Go using the global tracer.
+func Go(ctx context.Context, operation string, fn func(ctx context.Context) error, options ...Option) {
+	goWithTracer(GlobalTracer(), ctx, operation, fn, options...)
+}
+
+// GoDetached is Tracer.GoDetached using the global tracer.
+func GoDetached(ctx context.Context, operation string, fn func(ctx context.Context) error, options ...Option) {
+	goDetachedWithTracer(GlobalTracer(), ctx, operation, fn, options...)
+}
+
+func goWithTracer(t Interface, ctx context.Context, operation string, fn func(ctx context.Context) error, options ...Option) {
+	s, ctx, labels := startForGoroutine(t, ctx, operation, options...)
+	track(s)
+	go runInGoroutine(ctx, s, labels, fn)
+}
+
+func goDetachedWithTracer(t Interface, ctx context.Context, operation string, fn func(ctx context.Context) error, options ...Option) {
+	parent := SpanFromContext(ctx)
+	// 脱离父 span 和取消信号，再用 FollowFrom 关联
+	detached := ContextWithSpan(context.WithoutCancel(ctx), span.NoopSpan())
+	if !parent.IsNoop() {
+		options = append([]Option{FollowFrom(parent.SpanContext())}, options...)
+	}
+
+	s, detached, labels := startForGoroutine(t, detached, operation, options...)
+	track(s)
+	go runInGoroutine(detached, s, labels, fn)
+}
+
+// startForGoroutine starts a span for work that runs on a new goroutine.
+// The pprof labels must be set on that goroutine, not on the caller's, so for a Tracer with
+// PprofLabels the span is started without them and labels reports that the goroutine must apply them.
+func startForGoroutine(t Interface, ctx context.Context, operation string, options ...Option) (s span.Interface, spanCtx context.Context, labels bool) {
+	if tracer, ok := t.(*Tracer); ok {
+		started := tracer.startSpanFromContext(ctx, operation, options...)
+		return started, ContextWithSpan(ctx, started), tracer.pprofLabels
+	}
+
+	s, spanCtx = t.StartSpanFromContext(ctx, operation, options...)
+	return s, spanCtx, false
+}
+
+func runInGoroutine(ctx context.Context, s span.Interface, labels bool, fn func(ctx context.Context) error) {
+	// labels is only set for spans of a *Tracer
+	labelled, ok := s.(*span.Span)
+	if !labels || !ok {
+		runInSpan(ctx, s, fn)
+		return
+	}
+
+	doWithPprofLabels(ctx, labelled, func(ctx context.Context) {
+		runInSpan(ctx, s, fn)
+	})
+}
+
+func track(s span.Interface) {
+	if s.IsNoop() {
+		return
+	}
+	activeSpans.Store(s, struct{}{})
+}
+
+// runInSpan runs fn, records its error or panic on s, then finishes s.
+func runInSpan(ctx context.Context, s span.Interface, fn func(ctx context.Context) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.RecordError(fmt.Errorf("panic: %v", r), semconv.ExceptionStacktrace(string(debug.Stack())))
+		}
+		s.Finish()
+		activeSpans.Delete(s)
+	}()
+
+	s.RecordError(fn(ctx))
+}
+
+// WorkItem carries a value and the span context of its producer through a channel,
+// so a worker pool can continue the trace of the request that submitted the work.
+type WorkItem[T any] struct {
+	SpanContext span.SpanContext
+	Value       T
+}
+
+// NewWorkItem wraps value with the span context found in ctx.
+func NewWorkItem[T any](ctx context.Context, value T) WorkItem[T] {
+	var sc span.SpanContext
+	if s := SpanFromContext(ctx); !s.IsNoop() {
+		sc = s.SpanContext()
+	}
+
+	return WorkItem[T]{SpanContext: sc, Value: value}
+}
+
+// Submit sends value to a worker pool channel together with the span context in ctx.
+// It returns ctx.Err() if ctx is done before the pool accepts the item.
+func Submit[T any](ctx context.Context, ch chan<- WorkItem[T], value T) error {
+	select {
+	case ch <- NewWorkItem(ctx, value):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run processes the item on the current goroutine inside a child span of the producer span.
+// An item submitted without a trace is processed in a child span of the span in ctx, if any.
+// ctx is the worker's own context; its cancellation still applies and processors see its values.
+func (w WorkItem[T]) Run(ctx context.Context, t Interface, operation string, fn func(ctx context.Context, value T) error, options ...Option) {
+	if w.SpanContext.TraceID != "" {
+		// 只挂在生产者 span 下面，worker 自己的 span 属于另一个 trace
+		ctx = ContextWithSpan(ctx, span.NoopSpan())
+		options = append([]Option{ChildOf(w.SpanContext)}, options...)
+	}
+
+	s, ctx, labels := startForGoroutine(t, ctx, operation, options...)
+	track(s)
+	runInGoroutine(ctx, s, labels, func(ctx context.Context) error {
+		return fn(ctx, w.Value)
+	})
+}
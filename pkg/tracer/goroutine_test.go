@@ -0,0 +1,238 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"tracer/internal/testutil"
+	"tracer/pkg/processor"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+func TestWorkItemRun(t *testing.T) {
+	tracer := newTestTracer(t)
+
+	producer := tracer.StartSpan("produce")
+	defer producer.Finish()
+	worker := tracer.StartSpan("worker")
+	defer worker.Finish()
+	worker.SetBaggageItem("worker", "yes")
+	workerCtx := ContextWithSpan(context.Background(), worker)
+
+	tests := []struct {
+		name        string
+		item        WorkItem[int]
+		wantTraceID string
+		wantParent  string
+		wantBaggage string
+	}{
+		{
+			name:        "item with a trace continues the producer",
+			item:        NewWorkItem(ContextWithSpan(context.Background(), producer), 1),
+			wantTraceID: producer.SpanContext().TraceID,
+			wantParent:  producer.SpanContext().SpanID,
+		},
+		{
+			name:        "item without a trace continues the worker",
+			item:        NewWorkItem(context.Background(), 2),
+			wantTraceID: worker.SpanContext().TraceID,
+			wantParent:  worker.SpanContext().SpanID,
+			wantBaggage: "yes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *span.Span
+			tt.item.Run(workerCtx, tracer, "process", func(ctx context.Context, value int) error {
+				got = SpanFromContext(ctx).(*span.Span)
+				return nil
+			})
+
+			if got.Context.TraceID != tt.wantTraceID || got.Context.ParentID != tt.wantParent {
+				t.Fatalf("span in trace %s under %s, want trace %s under %s",
+					got.Context.TraceID, got.Context.ParentID, tt.wantTraceID, tt.wantParent)
+			}
+			if len(got.References) != 1 || got.References[0].TraceID != tt.wantTraceID {
+				t.Fatalf("references = %+v, want one reference into trace %s", got.References, tt.wantTraceID)
+			}
+			if baggage := got.GetBaggageItem("worker"); baggage != tt.wantBaggage {
+				t.Fatalf("worker baggage = %q, want %q", baggage, tt.wantBaggage)
+			}
+			if !got.IsFinished() {
+				t.Fatal("span not finished after Run")
+			}
+		})
+	}
+}
+
+func TestChildOfKeepsBaggageWithoutTrace(t *testing.T) {
+	opt := new(StartSpanOption)
+	ChildOf(span.SpanContext{TraceID: "t", SpanID: "s", Baggage: map[string]string{"k": "v"}}).Apply(opt)
+	ChildOf(span.SpanContext{Debug: true}).Apply(opt)
+
+	if opt.Baggage["k"] != "v" {
+		t.Fatalf("baggage = %v, want it kept", opt.Baggage)
+	}
+	if !opt.Debug || len(opt.References) != 1 {
+		t.Fatalf("debug = %v, references = %d, want debug and one reference", opt.Debug, len(opt.References))
+	}
+}
+
+// finishedSpans registers a processor on tr that sends every finished span on the returned channel.
+func finishedSpans(tr *Tracer) <-chan *span.ToModel {
+	ch := make(chan *span.ToModel, 16)
+	tr.RegisterProcessor(processor.NewFilterProcessor(func(s *span.ToModel) bool {
+		ch <- s
+		return true
+	}))
+	return ch
+}
+
+func waitSpan(t *testing.T, ch <-chan *span.ToModel) *span.ToModel {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("the goroutine span was not finished")
+		return nil
+	}
+}
+
+// waitNoUnfinishedSpans waits for the goroutines to remove their spans from UnfinishedSpans.
+func waitNoUnfinishedSpans(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(UnfinishedSpans()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("UnfinishedSpans = %v, want none", UnfinishedSpans())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGoRecordsErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		fn          func(ctx context.Context) error
+		wantError   bool
+		wantMessage string
+		wantStack   bool
+	}{
+		{"success", func(context.Context) error { return nil }, false, "", false},
+		{"error", func(context.Context) error { return errors.New("boom") }, true, "boom", false},
+		{"panic", func(context.Context) error { panic("boom") }, true, "panic: boom", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newTestTracer(t)
+			finished := finishedSpans(tr)
+			parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+			defer parent.Finish()
+
+			tr.Go(ctx, "work", tt.fn)
+			s := waitSpan(t, finished)
+
+			if s.Context.ParentID != parent.SpanContext().SpanID || s.Context.TraceID != parent.SpanContext().TraceID {
+				t.Fatalf("goroutine span under %s in %s, want a child of %s", s.Context.ParentID, s.Context.TraceID, parent.SpanContext().SpanID)
+			}
+			if errTag, _ := testutil.TagValue(s.Tags, semconv.ErrorKey); (errTag == true) != tt.wantError {
+				t.Fatalf("%s = %v, want %v", semconv.ErrorKey, errTag, tt.wantError)
+			}
+			if !tt.wantError {
+				return
+			}
+			if len(s.Logs) != 1 {
+				t.Fatalf("%d logs, want the exception event", len(s.Logs))
+			}
+			if message, _ := testutil.TagValue(s.Logs[0].Fields, semconv.ExceptionMessageKey); message != tt.wantMessage {
+				t.Fatalf("%s = %v, want %q", semconv.ExceptionMessageKey, message, tt.wantMessage)
+			}
+			stack, _ := testutil.TagValue(s.Logs[0].Fields, semconv.ExceptionStacktraceKey)
+			if stack, _ := stack.(string); strings.Contains(stack, "goroutine") != tt.wantStack {
+				t.Fatalf("%s = %q, want a stack trace: %v", semconv.ExceptionStacktraceKey, stack, tt.wantStack)
+			}
+		})
+	}
+	waitNoUnfinishedSpans(t)
+}
+
+func TestGoDetached(t *testing.T) {
+	tr := newTestTracer(t)
+	finished := finishedSpans(tr)
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "request")
+	ctx, cancel := context.WithCancel(ctx)
+
+	ctxErr := make(chan error, 1)
+	release := make(chan struct{})
+	tr.GoDetached(ctx, "background", func(ctx context.Context) error {
+		<-release
+		ctxErr <- ctx.Err()
+		return nil
+	})
+
+	// 请求结束后后台任务还在跑
+	cancel()
+	parent.Finish()
+	waitSpan(t, finished)
+	close(release)
+	s := waitSpan(t, finished)
+
+	if err := <-ctxErr; err != nil {
+		t.Fatalf("detached ctx = %v after the parent ctx was cancelled, want it still live", err)
+	}
+	sc := parent.SpanContext()
+	if s.Context.TraceID != sc.TraceID || s.Context.ParentID != "" {
+		t.Fatalf("detached span in %s under %q, want trace %s and no parent", s.Context.TraceID, s.Context.ParentID, sc.TraceID)
+	}
+	if len(s.References) != 1 || s.References[0].RefType != span.FollowFrom || s.References[0].SpanID != sc.SpanID {
+		t.Fatalf("references = %+v, want one FOLLOW_FROM %s", s.References, sc.SpanID)
+	}
+	waitNoUnfinishedSpans(t)
+}
+
+func TestUnfinishedSpans(t *testing.T) {
+	tr := newTestTracer(t)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	tr.Go(context.Background(), "leaky", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	spans := UnfinishedSpans()
+	if len(spans) != 1 || spans[0].(*span.Span).Operation != "leaky" {
+		t.Fatalf("UnfinishedSpans = %v, want the running goroutine's span", spans)
+	}
+
+	close(release)
+	waitNoUnfinishedSpans(t)
+}
+
+func TestWorkItemRunProcessorContext(t *testing.T) {
+	tr := newTestTracer(t)
+	var calls []string
+	tr.RegisterProcessor(&recordingProcessor{name: "p", calls: &calls})
+
+	producer := tr.StartSpan("produce")
+	defer producer.Finish()
+	item := NewWorkItem(ContextWithSpan(context.Background(), producer), 1)
+
+	workerCtx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	var got span.Interface
+	item.Run(workerCtx, tr, "process", func(ctx context.Context, value int) error {
+		got = SpanFromContext(ctx)
+		return nil
+	})
+
+	if tenant, _ := testutil.TagValue(got.(*span.Span).ToModel().Tags, "tenant"); tenant != "acme" {
+		t.Fatalf("tenant = %v, want the processor to see the worker ctx", tenant)
+	}
+}
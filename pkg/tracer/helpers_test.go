@@ -0,0 +1,62 @@
+package tracer
+
+import (
+	"testing"
+	"tracer/pkg/config"
+	"tracer/pkg/metrics"
+)
+
+// testTracerOption changes the tracer built by newTestTracer.
+type testTracerOption func(conf *config.Configuration, options *[]TracerOption)
+
+// withSamplerParam sets the param of the const sampler; 0 samples nothing.
+func withSamplerParam(param float64) testTracerOption {
+	return func(conf *config.Configuration, _ *[]TracerOption) {
+		conf.Sampler.Param = param
+	}
+}
+
+func withMetrics(f metrics.Factory) testTracerOption {
+	return func(conf *config.Configuration, _ *[]TracerOption) {
+		conf.Metrics = f
+	}
+}
+
+func withPprofLabels() testTracerOption {
+	return func(conf *config.Configuration, _ *[]TracerOption) {
+		conf.PprofLabels = config.Bool(true)
+	}
+}
+
+func withAgentAddr(addr string) testTracerOption {
+	return func(conf *config.Configuration, _ *[]TracerOption) {
+		conf.Reporter = &config.ReporterConfig{AgentAddr: addr}
+	}
+}
+
+func withTracerOptions(tracerOptions ...TracerOption) testTracerOption {
+	return func(_ *config.Configuration, options *[]TracerOption) {
+		*options = append(*options, tracerOptions...)
+	}
+}
+
+// newTestTracer returns a tracer for the test service that samples every span.
+// Its reporter is closed when the test ends.
+func newTestTracer(t testing.TB, options ...testTracerOption) *Tracer {
+	t.Helper()
+	conf := &config.Configuration{
+		ServiceName: "test",
+		Sampler:     &config.SamplerConfig{Type: "const", Param: 1},
+	}
+	var tracerOptions []TracerOption
+	for _, option := range options {
+		option(conf, &tracerOptions)
+	}
+
+	tr, err := NewTracer(conf, tracerOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tr.Reporter.Close() })
+	return tr
+}
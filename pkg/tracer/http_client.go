@@ -0,0 +1,168 @@
+package tracer
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+	"tracer/pkg/config"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+// Transport is an http.RoundTripper that starts a client span for every request,
+// as a child of the span in the request context, and injects the span context into the request headers.
+// The span covers the round trip up to the response headers.
+type Transport struct {
+	tracer      Interface
+	base        http.RoundTripper
+	clientTrace bool
+	urlQuery    bool
+	operation   func(r *http.Request) string
+}
+
+type TransportOption func(t *Transport)
+
+// WithClientTrace records DNS, connect, TLS handshake, connection reuse, wrote-request
+// and first-response-byte as timestamped events on the client span.
+func WithClientTrace() TransportOption {
+	return func(t *Transport) {
+		t.clientTrace = true
+	}
+}
+
+// WithURLQuery keeps the query string in the http.url tag. It is dropped by default because
+// it often carries tokens or personal data. Credentials in the URL are never recorded.
+func WithURLQuery() TransportOption {
+	return func(t *Transport) {
+		t.urlQuery = true
+	}
+}
+
+// WithOperationName sets how the span name is built from the request. The default is "HTTP GET" etc.
+func WithOperationName(f func(r *http.Request) string) TransportOption {
+	return func(t *Transport) {
+		t.operation = f
+	}
+}
+
+// NewTransport wraps base, or http.DefaultTransport when base is nil.
+func NewTransport(t Interface, base http.RoundTripper, options ...TransportOption) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	transport := &Transport{
+		tracer: t,
+		base:   base,
+		operation: func(r *http.Request) string {
+			return "HTTP " + r.Method
+		},
+	}
+	for _, option := range options {
+		option(transport)
+	}
+
+	return transport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s, ctx := t.tracer.StartSpanFromContext(req.Context(), t.operation(req),
+		WithTags(
+			semconv.SpanKind(semconv.SpanKindClient),
+			semconv.HTTPMethod(req.Method),
+			semconv.HTTPURL(t.spanURL(req.URL))))
+	defer s.Finish()
+
+	sc := s.SpanContext()
+	if t.clientTrace && sc.Sampled {
+		ctx = httptrace.WithClientTrace(ctx, newClientTrace(s))
+	}
+
+	// RoundTripper 不能修改原请求，注入前先 Clone
+	req = req.Clone(ctx)
+	if !s.IsNoop() {
+		_ = t.tracer.Inject(sc, &HttpCarrier{Header: req.Header})
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		s.RecordError(err)
+		return nil, err
+	}
+
+	s.SetTags(semconv.HTTPStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		s.SetTags(semconv.Error(true))
+	}
+
+	return resp, nil
+}
+
+// spanURL returns u without userinfo and fragment, and without the query unless WithURLQuery is set.
+func (t *Transport) spanURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.Fragment = ""
+	redacted.RawFragment = ""
+	if !t.urlQuery {
+		redacted.RawQuery = ""
+		redacted.ForceQuery = false
+	}
+
+	return redacted.String()
+}
+
+// newClientTrace logs each httptrace hook as an event on s at the time it fires.
+func newClientTrace(s span.Interface) *httptrace.ClientTrace {
+	event := func(name string, err error, fields ...config.Tag) {
+		fields = append([]config.Tag{semconv.Event(name)}, fields...)
+		if err != nil {
+			fields = append(fields, semconv.ExceptionMessage(err.Error()))
+		}
+		s.LogFieldsAt(time.Now(), fields...)
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			event("dns.start", nil, semconv.NetHostName(info.Host))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			addrs := make([]string, 0, len(info.Addrs))
+			for _, addr := range info.Addrs {
+				addrs = append(addrs, addr.String())
+			}
+			event("dns.done", info.Err, semconv.NetPeerIPs(addrs))
+		},
+		ConnectStart: func(network, addr string) {
+			event("connect.start", nil, semconv.NetTransport(network), semconv.NetPeerAddr(addr))
+		},
+		ConnectDone: func(network, addr string, err error) {
+			event("connect.done", err, semconv.NetTransport(network), semconv.NetPeerAddr(addr))
+		},
+		TLSHandshakeStart: func() {
+			event("tls.start", nil)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			event("tls.done", err,
+				semconv.TLSVersion(state.Version),
+				semconv.TLSCipher(state.CipherSuite),
+				semconv.TLSServerName(state.ServerName),
+				semconv.TLSResumed(state.DidResume))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			fields := []config.Tag{semconv.NetConnReused(info.Reused), semconv.NetConnWasIdle(info.WasIdle)}
+			if info.WasIdle {
+				fields = append(fields, semconv.NetConnIdleTime(info.IdleTime))
+			}
+			event("got_conn", nil, fields...)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			event("wrote_request", info.Err)
+		},
+		GotFirstResponseByte: func() {
+			event("first_response_byte", nil)
+		},
+	}
+}
@@ -0,0 +1,142 @@
+package tracer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"tracer/internal/testutil"
+	"tracer/pkg/config"
+	"tracer/pkg/processor"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+// captureSpans registers a processor on tr that keeps every finished span.
+func captureSpans(tr *Tracer) func() []*span.ToModel {
+	var (
+		mu    sync.Mutex
+		spans []*span.ToModel
+	)
+	tr.RegisterProcessor(processor.NewFilterProcessor(func(s *span.ToModel) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		spans = append(spans, s)
+		return true
+	}))
+
+	return func() []*span.ToModel {
+		mu.Lock()
+		defer mu.Unlock()
+		return spans
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tr := newTestTracer(t)
+	finished := captureSpans(tr)
+	client := &http.Client{Transport: NewTransport(tr, nil, WithClientTrace())}
+
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+	defer parent.Finish()
+
+	target, _ := url.Parse(server.URL + "/user/1?token=secret#frag")
+	target.User = url.UserPassword("bob", "hunter2")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(req.Header) != 0 {
+		t.Fatalf("the caller's request headers were changed: %v", req.Header)
+	}
+
+	spans := finished()
+	if len(spans) != 1 {
+		t.Fatalf("%d finished spans, want the client span", len(spans))
+	}
+	clientSpan := spans[0]
+
+	injected, err := tr.Extract(&HttpCarrier{Header: received})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if injected.TraceID != clientSpan.Context.TraceID || injected.SpanID != clientSpan.Context.SpanID {
+		t.Fatalf("server got %s/%s, want the client span %s/%s",
+			injected.TraceID, injected.SpanID, clientSpan.Context.TraceID, clientSpan.Context.SpanID)
+	}
+	if clientSpan.Context.ParentID != parent.SpanContext().SpanID {
+		t.Fatalf("client span parent = %q, want %q", clientSpan.Context.ParentID, parent.SpanContext().SpanID)
+	}
+
+	tags := make(map[string]interface{})
+	for _, tag := range clientSpan.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	if tags[semconv.HTTPStatusCodeKey] != http.StatusServiceUnavailable {
+		t.Errorf("%s = %v, want 503", semconv.HTTPStatusCodeKey, tags[semconv.HTTPStatusCodeKey])
+	}
+	if tags[semconv.ErrorKey] != true {
+		t.Errorf("%s = %v, want true for a 5xx response", semconv.ErrorKey, tags[semconv.ErrorKey])
+	}
+	if want := server.URL + "/user/1"; tags[semconv.HTTPURLKey] != want {
+		t.Errorf("%s = %v, want %s without credentials, query or fragment", semconv.HTTPURLKey, tags[semconv.HTTPURLKey], want)
+	}
+
+	events := make(map[string][]config.Tag)
+	for _, log := range clientSpan.Logs {
+		if event, ok := testutil.TagValue(log.Fields, semconv.EventKey); ok {
+			events[event.(string)] = log.Fields
+		}
+	}
+	for _, event := range []string{"connect.start", "connect.done", "got_conn", "wrote_request", "first_response_byte"} {
+		if _, ok := events[event]; !ok {
+			t.Errorf("client span has no %s event, got %v", event, events)
+		}
+	}
+	if reused, ok := testutil.TagValue(events["got_conn"], semconv.NetConnReusedKey); !ok || reused != false {
+		t.Errorf("got_conn %s = %v, want false on the first request", semconv.NetConnReusedKey, reused)
+	}
+}
+
+func TestTransportURLQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	tr := newTestTracer(t)
+	finished := captureSpans(tr)
+	client := &http.Client{Transport: NewTransport(tr, nil, WithURLQuery())}
+
+	resp, err := client.Get(server.URL + "/search?q=go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := finished()
+	if len(spans) != 1 {
+		t.Fatalf("%d finished spans, want 1", len(spans))
+	}
+	for _, tag := range spans[0].Tags {
+		if tag.Key == semconv.HTTPURLKey && tag.Value != server.URL+"/search?q=go" {
+			t.Fatalf("%s = %v, want the query kept", semconv.HTTPURLKey, tag.Value)
+		}
+	}
+	for _, log := range spans[0].Logs {
+		t.Fatalf("client span has log %v without WithClientTrace", log.Fields)
+	}
+}
@@ -0,0 +1,31 @@
+package tracer
+
+import (
+	"tracer/pkg/config"
+	"tracer/pkg/span"
+)
+
+type LinkOption struct {
+	ctx        span.SpanContext
+	attributes []config.Tag
+}
+
+func (o *LinkOption) Apply(s *StartSpanOption) {
+	if o.ctx.TraceID == "" {
+		return
+	}
+
+	s.References = append(s.References, span.Reference{
+		RefType:    span.Link,
+		TraceID:    o.ctx.TraceID,
+		SpanID:     o.ctx.SpanID,
+		Attributes: o.attributes,
+	})
+}
+
+// Link points the new span to a related span, typically in another trace, such as
+// each producer span of a batch of consumed messages. Unlike ChildOf and FollowFrom
+// it neither chooses the trace of the new span nor copies baggage.
+func Link(ctx span.SpanContext, attributes ...config.Tag) *LinkOption {
+	return &LinkOption{ctx: ctx, attributes: attributes}
+}
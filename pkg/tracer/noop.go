@@ -0,0 +1,50 @@
+package tracer
+
+import (
+	"context"
+	"tracer/pkg/span"
+)
+
+// Interface is the API shared by Tracer and NoopTracer.
+// Code that may run with tracing disabled should depend on it instead of *Tracer.
+type Interface interface {
+	StartSpan(operation string, options ...Option) span.Interface
+	StartSpanFromContext(ctx context.Context, operation string, options ...Option) (span.Interface, context.Context)
+	Inject(sc span.SpanContext, carrier Carrier) error
+	Extract(carrier Carrier) (span.SpanContext, error)
+	SpanFromContext(ctx context.Context) span.Interface
+	ContextFromSpan(ctx context.Context, span span.Interface) context.Context
+}
+
+var (
+	_ Interface = (*Tracer)(nil)
+	_ Interface = NoopTracer{}
+)
+
+// NoopTracer creates no spans, opens no socket and allocates nothing.
+// Every span it returns is span.NoopSpan().
+type NoopTracer struct{}
+
+func (NoopTracer) StartSpan(string, ...Option) span.Interface {
+	return span.NoopSpan()
+}
+
+func (NoopTracer) StartSpanFromContext(ctx context.Context, _ string, _ ...Option) (span.Interface, context.Context) {
+	return span.NoopSpan(), ctx
+}
+
+func (NoopTracer) Inject(span.SpanContext, Carrier) error {
+	return nil
+}
+
+func (NoopTracer) Extract(Carrier) (span.SpanContext, error) {
+	return span.SpanContext{}, nil
+}
+
+func (NoopTracer) SpanFromContext(ctx context.Context) span.Interface {
+	return SpanFromContext(ctx)
+}
+
+func (NoopTracer) ContextFromSpan(ctx context.Context, s span.Interface) context.Context {
+	return ContextWithSpan(ctx, s)
+}
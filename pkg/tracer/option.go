@@ -1,6 +1,7 @@
 package tracer
 
 import (
+	"time"
 	"tracer/pkg/config"
 	"tracer/pkg/span"
 )
@@ -9,9 +10,15 @@ type Option interface {
 	Apply(*StartSpanOption)
 }
 
+// emptyStartSpanOption is used when StartSpan gets no options. It must never be modified.
+var emptyStartSpanOption = &StartSpanOption{}
+
 type StartSpanOption struct {
 	TracerID   string
 	References []span.Reference
 	Tags       []config.Tag
 	Baggage    map[string]string
+	Debug      bool
+	DebugID    string
+	StartTime  time.Time
 }
@@ -0,0 +1,56 @@
+package tracer
+
+import (
+	"context"
+	"runtime/pprof"
+	"tracer/pkg/span"
+)
+
+const (
+	SpanIDProfileLabel    = "span_id"
+	OperationProfileLabel = "operation"
+	// ProfileIDTagKey holds the span_id label value, so a span can be matched with its profile samples.
+	ProfileIDTagKey = "profile.id"
+)
+
+// applyPprofLabels labels the current goroutine with the span ID and operation and
+// returns a context carrying the labels, so goroutines started with pprof.Do inherit them.
+// The labels of parent are restored when the span finishes, which must then happen on
+// the same goroutine (the usual defer s.Finish()). Unsampled spans are left alone.
+func applyPprofLabels(parent context.Context, s *span.Span) context.Context {
+	sc := s.SpanContext()
+	if !sc.Sampled {
+		return parent
+	}
+
+	ctx := pprof.WithLabels(parent, pprofLabels(s, sc))
+	pprof.SetGoroutineLabels(ctx)
+
+	s.SetTag(ProfileIDTagKey, sc.SpanID)
+	s.AddFinishHook(func() {
+		pprof.SetGoroutineLabels(parent)
+	})
+
+	return ctx
+}
+
+// doWithPprofLabels runs f with the goroutine labelled for s, and restores the labels when f returns.
+// Unlike applyPprofLabels it does not depend on where the span finishes, so it suits spans started
+// for another goroutine: the caller's labels are never touched.
+func doWithPprofLabels(ctx context.Context, s *span.Span, f func(ctx context.Context)) {
+	sc := s.SpanContext()
+	if !sc.Sampled {
+		f(ctx)
+		return
+	}
+
+	s.SetTag(ProfileIDTagKey, sc.SpanID)
+	pprof.Do(ctx, pprofLabels(s, sc), f)
+}
+
+func pprofLabels(s *span.Span, sc span.SpanContext) pprof.LabelSet {
+	return pprof.Labels(
+		SpanIDProfileLabel, sc.SpanID,
+		OperationProfileLabel, s.Operation,
+	)
+}
@@ -0,0 +1,95 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"tracer/pkg/span"
+)
+
+func ctxLabels(ctx context.Context) map[string]string {
+	labels := make(map[string]string)
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels[key] = value
+		return true
+	})
+	return labels
+}
+
+// goroutineProfile returns the goroutine profile, which lists the labels of every goroutine.
+func goroutineProfile(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestPprofLabels(t *testing.T) {
+	tr := newTestTracer(t, withPprofLabels())
+	parentCtx := pprof.WithLabels(context.Background(), pprof.Labels("request", "r1"))
+
+	started := make(chan span.Interface)
+	finish := make(chan struct{})
+	finished := make(chan struct{})
+	var labels map[string]string
+	go func() {
+		pprof.SetGoroutineLabels(parentCtx)
+		s, ctx := tr.StartSpanFromContext(parentCtx, "op")
+		labels = ctxLabels(ctx)
+		started <- s
+		<-finish
+		s.Finish()
+		finished <- struct{}{}
+		<-finish
+	}()
+
+	s := <-started
+	spanID := s.SpanContext().SpanID
+	if labels[SpanIDProfileLabel] != spanID || labels[OperationProfileLabel] != "op" || labels["request"] != "r1" {
+		t.Fatalf("ctx labels = %v, want span_id %s, operation op and the parent's request label", labels, spanID)
+	}
+	if !strings.Contains(goroutineProfile(t), spanID) {
+		t.Fatal("the goroutine is not labelled with the span ID while the span runs")
+	}
+
+	var profileID interface{}
+	for _, tag := range s.(*span.Span).ToModel().Tags {
+		if tag.Key == ProfileIDTagKey {
+			profileID = tag.Value
+		}
+	}
+	if profileID != spanID {
+		t.Fatalf("%s tag = %v, want %s", ProfileIDTagKey, profileID, spanID)
+	}
+
+	finish <- struct{}{}
+	<-finished
+	defer close(finish)
+	profile := goroutineProfile(t)
+	if strings.Contains(profile, spanID) {
+		t.Fatal("the span_id label is still set after Finish")
+	}
+	if !strings.Contains(profile, `"request":"r1"`) {
+		t.Fatal("the parent's labels were not restored after Finish")
+	}
+}
+
+func TestPprofLabelsUnsampled(t *testing.T) {
+	tr := newTestTracer(t, withPprofLabels(), withSamplerParam(0))
+
+	s, ctx := tr.StartSpanFromContext(context.Background(), "op")
+	defer s.Finish()
+
+	if labels := ctxLabels(ctx); len(labels) != 0 {
+		t.Fatalf("unsampled span labelled the ctx with %v", labels)
+	}
+	for _, tag := range s.(*span.Span).ToModel().Tags {
+		if tag.Key == ProfileIDTagKey {
+			t.Fatalf("unsampled span has the %s tag", ProfileIDTagKey)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package tracer
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"tracer/pkg/metrics"
+	"tracer/pkg/processor"
+	"tracer/pkg/span"
+)
+
+type tenantKey struct{}
+
+// recordingProcessor appends its name to calls and copies the tenant from ctx into a tag.
+type recordingProcessor struct {
+	name  string
+	calls *[]string
+	drop  bool
+}
+
+func (p *recordingProcessor) OnStart(ctx context.Context, s *span.Span) {
+	*p.calls = append(*p.calls, p.name+".OnStart")
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		s.SetTag("tenant", tenant)
+	}
+}
+
+func (p *recordingProcessor) OnEnd(s *span.ToModel) {
+	*p.calls = append(*p.calls, p.name+".OnEnd")
+	if p.drop {
+		s.Context.Sampled = false
+	}
+}
+
+// reporterQueueLength returns the tracer_reporter_queue_length line of the Prometheus output.
+func reporterQueueLength(t *testing.T, f *metrics.PrometheusFactory) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, "tracer_reporter_queue_length ") {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestSpanProcessorChain(t *testing.T) {
+	tr := newTestTracer(t)
+
+	var calls []string
+	tr.RegisterProcessor(
+		&recordingProcessor{name: "a", calls: &calls},
+		&recordingProcessor{name: "b", calls: &calls},
+	)
+
+	var tenant interface{}
+	tr.RegisterProcessor(processor.NewFilterProcessor(func(s *span.ToModel) bool {
+		for _, tag := range s.Tags {
+			if tag.Key == "tenant" {
+				tenant = tag.Value
+			}
+		}
+		return true
+	}))
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	s, _ := tr.StartSpanFromContext(ctx, "op")
+	s.Finish()
+
+	want := []string{"a.OnStart", "b.OnStart", "a.OnEnd", "b.OnEnd"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	if tenant != "acme" {
+		t.Fatalf("tenant tag = %v, want acme from the StartSpanFromContext ctx", tenant)
+	}
+
+	calls = nil
+	tr.StartSpan("op").Finish()
+	if !slices.Equal(calls, want) {
+		t.Fatalf("StartSpan calls = %v, want %v", calls, want)
+	}
+}
+
+func TestReporterProcessorRunsLast(t *testing.T) {
+	tests := []struct {
+		name string
+		drop bool
+		want string
+	}{
+		{"kept", false, "tracer_reporter_queue_length 1"},
+		{"dropped by an earlier processor", true, "tracer_reporter_queue_length 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := metrics.NewPrometheusFactory()
+			tr := newTestTracer(t, withMetrics(f))
+
+			var calls []string
+			tr.RegisterProcessor(&recordingProcessor{name: "p", calls: &calls, drop: tt.drop})
+			tr.StartSpan("op").Finish()
+
+			if got := reporterQueueLength(t, f); got != tt.want {
+				t.Fatalf("queue length line = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
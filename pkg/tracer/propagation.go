@@ -0,0 +1,153 @@
+package tracer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"tracer/pkg/config"
+	"tracer/pkg/span"
+)
+
+// Propagator writes a SpanContext into a carrier and reads it back using one header format.
+type Propagator interface {
+	Inject(sc span.SpanContext, carrier Carrier)
+	// Extract returns false when the carrier holds no context in this format.
+	Extract(carrier Carrier) (span.SpanContext, bool)
+}
+
+// NewPropagators returns the propagators for the given formats, in order.
+func NewPropagators(formats []string) ([]Propagator, error) {
+	if len(formats) == 0 {
+		formats = []string{config.PropagationTracer}
+	}
+
+	propagators := make([]Propagator, 0, len(formats))
+	for _, format := range formats {
+		switch format {
+		case config.PropagationTracer:
+			propagators = append(propagators, TracerPropagator{})
+		case config.PropagationB3:
+			propagators = append(propagators, B3Propagator{})
+		default:
+			return nil, fmt.Errorf("unknown propagation format %q", format)
+		}
+	}
+
+	return propagators, nil
+}
+
+// DebugIDHeader forces the request to be traced. Its value is a correlation ID chosen by the caller
+// (for example a support ticket number) and is recorded in the tracer.debug_id tag.
+const DebugIDHeader = "tracer-debug-id"
+
+// extractDebugID returns the value of the debug header, whatever the propagation format.
+func extractDebugID(carrier Carrier) string {
+	var debugID string
+	carrier.Foreach(func(key string, v interface{}) {
+		if value, ok := v.(string); ok && strings.ToLower(key) == DebugIDHeader {
+			debugID = value
+		}
+	})
+
+	return debugID
+}
+
+// TracerPropagator is the native format: tracer_id, span_id, sampled, debug and baggage_* keys.
+type TracerPropagator struct{}
+
+func (TracerPropagator) Inject(sc span.SpanContext, carrier Carrier) {
+	carrier.Set("tracer_id", sc.TraceID)
+	carrier.Set("span_id", sc.SpanID)
+	carrier.Set("sampled", strconv.FormatBool(sc.Sampled))
+	if sc.Debug {
+		carrier.Set("debug", "true")
+	}
+	if sc.DebugID != "" {
+		carrier.Set(DebugIDHeader, sc.DebugID)
+	}
+
+	sc.ForeachBaggageItem(func(k, v string) {
+		carrier.Set("baggage_"+k, v)
+	})
+}
+
+func (TracerPropagator) Extract(carrier Carrier) (span.SpanContext, bool) {
+	sc := span.NewSpanContext()
+
+	carrier.Foreach(func(key string, v interface{}) {
+		value, ok := v.(string)
+		if !ok {
+			return
+		}
+
+		// http.Header 会把 key 规范化成 Tracer_id 这种形式，统一转小写再比较
+		key = strings.ToLower(key)
+		switch key {
+		case "tracer_id":
+			sc.TraceID = value
+		case "span_id":
+			sc.SpanID = value
+		case "sampled":
+			sc.Sampled, _ = strconv.ParseBool(value)
+		case "debug":
+			sc.Debug, _ = strconv.ParseBool(value)
+		default:
+			if strings.HasPrefix(key, "baggage_") {
+				realKey := strings.TrimPrefix(key, "baggage_")
+				sc.Baggage[realKey] = value
+			}
+		}
+	})
+
+	return sc, sc.TraceID != ""
+}
+
+const (
+	b3TraceID = "x-b3-traceid"
+	b3SpanID  = "x-b3-spanid"
+	b3Sampled = "x-b3-sampled"
+	b3Flags   = "x-b3-flags"
+)
+
+// B3Propagator is the Zipkin B3 multi-header format.
+type B3Propagator struct{}
+
+func (B3Propagator) Inject(sc span.SpanContext, carrier Carrier) {
+	carrier.Set(b3TraceID, sc.TraceID)
+	carrier.Set(b3SpanID, sc.SpanID)
+	switch {
+	case sc.Debug:
+		carrier.Set(b3Flags, "1") // debug 隐含 sampled，B3 规定此时不再发 X-B3-Sampled
+	case sc.Sampled:
+		carrier.Set(b3Sampled, "1")
+	default:
+		carrier.Set(b3Sampled, "0")
+	}
+}
+
+func (B3Propagator) Extract(carrier Carrier) (span.SpanContext, bool) {
+	sc := span.NewSpanContext()
+
+	carrier.Foreach(func(key string, v interface{}) {
+		value, ok := v.(string)
+		if !ok {
+			return
+		}
+
+		switch strings.ToLower(key) {
+		case b3TraceID:
+			sc.TraceID = strings.ToLower(value)
+		case b3SpanID:
+			sc.SpanID = strings.ToLower(value)
+		case b3Sampled:
+			sc.Sampled = value == "1" || value == "true"
+		case b3Flags:
+			if value == "1" {
+				sc.Sampled = true
+				sc.Debug = true
+			}
+		}
+	})
+
+	return sc, sc.TraceID != ""
+}
@@ -0,0 +1,171 @@
+package tracer
+
+import (
+	"context"
+	"log/slog"
+	"tracer/pkg/config"
+	"tracer/pkg/semconv"
+)
+
+const (
+	TraceIDLogKey = "trace_id"
+	SpanIDLogKey  = "span_id"
+)
+
+// SlogHandler wraps a slog.Handler and adds trace_id and span_id to every record
+// logged with a context that carries a span. Optionally it mirrors records at or
+// above a level into the span as log events, so traces show the relevant log lines.
+// Like any record attribute, the IDs end up inside the group opened by WithGroup, if any.
+type SlogHandler struct {
+	next       slog.Handler
+	eventLevel slog.Leveler
+
+	// attrs 和 groups 记录 WithAttrs/WithGroup 的结果，镜像到 span 时需要
+	attrs  []config.Tag
+	groups []string
+}
+
+type SlogOption func(h *SlogHandler)
+
+// WithSpanEvents mirrors records at or above level into the current span.
+func WithSpanEvents(level slog.Leveler) SlogOption {
+	return func(h *SlogHandler) {
+		h.eventLevel = level
+	}
+}
+
+// NewSlogHandler creates a new SlogHandler around next.
+func NewSlogHandler(next slog.Handler, options ...SlogOption) *SlogHandler {
+	h := &SlogHandler{next: next}
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
+
+// Enabled reports whether the wrapped handler or the span mirroring wants the level.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || h.mirrors(level)
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	s := SpanFromContext(ctx)
+	if s.IsNoop() {
+		if !h.next.Enabled(ctx, record.Level) {
+			return nil
+		}
+		return h.next.Handle(ctx, record)
+	}
+
+	sc := s.SpanContext()
+	if h.mirrors(record.Level) && sc.Sampled {
+		fields := make([]config.Tag, 0, 2+len(h.attrs)+record.NumAttrs())
+		fields = append(fields,
+			semconv.Event(record.Message),
+			config.Tag{Key: "level", Value: record.Level.String()},
+		)
+		fields = append(fields, h.attrs...)
+		record.Attrs(func(attr slog.Attr) bool {
+			fields = appendAttr(fields, h.prefix(), attr)
+			return true
+		})
+		s.LogFieldsAt(record.Time, fields...)
+	}
+
+	if !h.next.Enabled(ctx, record.Level) {
+		return nil
+	}
+
+	record = record.Clone()
+	record.AddAttrs(
+		slog.String(TraceIDLogKey, sc.TraceID),
+		slog.String(SpanIDLogKey, sc.SpanID),
+	)
+
+	return h.next.Handle(ctx, record)
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := h.clone()
+	clone.next = h.next.WithAttrs(attrs)
+	for _, attr := range attrs {
+		clone.attrs = appendAttr(clone.attrs, h.prefix(), attr)
+	}
+
+	return clone
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := h.clone()
+	clone.next = h.next.WithGroup(name)
+	clone.groups = append(clone.groups, name)
+
+	return clone
+}
+
+func (h *SlogHandler) clone() *SlogHandler {
+	return &SlogHandler{
+		next:       h.next,
+		eventLevel: h.eventLevel,
+		attrs:      append([]config.Tag(nil), h.attrs...),
+		groups:     append([]string(nil), h.groups...),
+	}
+}
+
+func (h *SlogHandler) mirrors(level slog.Level) bool {
+	return h.eventLevel != nil && level >= h.eventLevel.Level()
+}
+
+func (h *SlogHandler) prefix() string {
+	var prefix string
+	for _, group := range h.groups {
+		prefix += group + "."
+	}
+	return prefix
+}
+
+// appendAttr flattens an attribute into span fields, keeping the value typed.
+// Groups become dotted keys, e.g. http.status.
+func appendAttr(fields []config.Tag, prefix string, attr slog.Attr) []config.Tag {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range value.Group() {
+			fields = appendAttr(fields, prefix, a)
+		}
+		return fields
+	}
+
+	if attr.Key == "" {
+		return fields
+	}
+
+	var v interface{}
+	switch value.Kind() {
+	case slog.KindString:
+		v = value.String()
+	case slog.KindInt64:
+		v = value.Int64()
+	case slog.KindUint64:
+		v = value.Uint64()
+	case slog.KindFloat64:
+		v = value.Float64()
+	case slog.KindBool:
+		v = value.Bool()
+	case slog.KindDuration:
+		v = value.Duration()
+	case slog.KindTime:
+		v = value.Time()
+	default:
+		v = value.Any()
+	}
+
+	return append(fields, config.Tag{Key: prefix + attr.Key, Value: v})
+}
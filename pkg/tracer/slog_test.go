@@ -0,0 +1,144 @@
+package tracer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+	"tracer/internal/testutil"
+	"tracer/pkg/config"
+	"tracer/pkg/semconv"
+	"tracer/pkg/span"
+)
+
+// newSlogLogger returns a logger writing JSON lines to buf through a SlogHandler.
+func newSlogLogger(buf *bytes.Buffer, options ...SlogOption) *slog.Logger {
+	return slog.New(NewSlogHandler(slog.NewJSONHandler(buf, nil), options...))
+}
+
+// lastLine decodes the last JSON line written to buf.
+func lastLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var out map[string]interface{}
+	if err := json.Unmarshal(lines[len(lines)-1], &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// spanLogs returns the fields of every log recorded on s.
+func spanLogs(s span.Interface) [][]config.Tag {
+	var logs [][]config.Tag
+	for _, log := range s.(*span.Span).ToModel().Logs {
+		logs = append(logs, log.Fields)
+	}
+	return logs
+}
+
+func TestSlogHandlerTraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newSlogLogger(&buf)
+
+	logger.InfoContext(context.Background(), "no span")
+	line := lastLine(t, &buf)
+	if _, ok := line[TraceIDLogKey]; ok {
+		t.Fatalf("record without a span has %s: %v", TraceIDLogKey, line)
+	}
+	if _, ok := line[SpanIDLogKey]; ok {
+		t.Fatalf("record without a span has %s: %v", SpanIDLogKey, line)
+	}
+
+	s, ctx := newTestTracer(t).StartSpanFromContext(context.Background(), "op")
+	defer s.Finish()
+	logger.InfoContext(ctx, "with span")
+
+	line = lastLine(t, &buf)
+	sc := s.SpanContext()
+	if line[TraceIDLogKey] != sc.TraceID || line[SpanIDLogKey] != sc.SpanID {
+		t.Fatalf("record = %v, want trace_id %s and span_id %s", line, sc.TraceID, sc.SpanID)
+	}
+}
+
+func TestSlogHandlerSpanEvents(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newSlogLogger(&buf, WithSpanEvents(slog.LevelWarn))
+
+	s, ctx := newTestTracer(t).StartSpanFromContext(context.Background(), "op")
+	defer s.Finish()
+
+	logger.InfoContext(ctx, "below the level")
+	logger.With("user", "bob").WithGroup("http").With("method", "GET").WarnContext(ctx, "slow request",
+		slog.Int("status", 503),
+		slog.Bool("retry", true),
+		slog.Duration("elapsed", 2*time.Second),
+		slog.Group("peer", slog.String("host", "db")),
+	)
+
+	logs := spanLogs(s)
+	if len(logs) != 1 {
+		t.Fatalf("%d span logs, want only the record at or above WARN", len(logs))
+	}
+
+	tests := []struct {
+		key  string
+		want interface{}
+	}{
+		{semconv.EventKey, "slow request"},
+		{"level", "WARN"},
+		{"user", "bob"},
+		{"http.method", "GET"},
+		{"http.status", int64(503)},
+		{"http.retry", true},
+		{"http.elapsed", 2 * time.Second},
+		{"http.peer.host", "db"},
+	}
+	for _, tt := range tests {
+		got, ok := testutil.TagValue(logs[0], tt.key)
+		if !ok {
+			t.Errorf("span event has no %s field: %v", tt.key, logs[0])
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.key, got, tt.want)
+		}
+	}
+
+	// the wrapped handler still gets the record, with the IDs inside the group
+	line := lastLine(t, &buf)
+	group, _ := line["http"].(map[string]interface{})
+	if group[TraceIDLogKey] != s.SpanContext().TraceID {
+		t.Fatalf("record = %v, want trace_id inside the http group", line)
+	}
+}
+
+func TestSlogHandlerUnsampledSpan(t *testing.T) {
+	tr := newTestTracer(t, withSamplerParam(0))
+
+	var buf bytes.Buffer
+	logger := newSlogLogger(&buf, WithSpanEvents(slog.LevelInfo))
+
+	s, ctx := tr.StartSpanFromContext(context.Background(), "op")
+	defer s.Finish()
+	logger.ErrorContext(ctx, "failed")
+
+	if logs := spanLogs(s); len(logs) != 0 {
+		t.Fatalf("unsampled span has %d logs, want none", len(logs))
+	}
+	if line := lastLine(t, &buf); line[TraceIDLogKey] != s.SpanContext().TraceID {
+		t.Fatalf("record = %v, want the trace_id of the unsampled span", line)
+	}
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	next := slog.NewJSONHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelError})
+
+	if NewSlogHandler(next).Enabled(context.Background(), slog.LevelWarn) {
+		t.Fatal("WARN enabled although neither the handler nor the span events want it")
+	}
+	if !NewSlogHandler(next, WithSpanEvents(slog.LevelWarn)).Enabled(context.Background(), slog.LevelWarn) {
+		t.Fatal("WARN disabled although span events want it")
+	}
+}
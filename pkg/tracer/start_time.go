@@ -0,0 +1,17 @@
+package tracer
+
+import "time"
+
+type StartTimeOption struct {
+	t time.Time
+}
+
+func (o *StartTimeOption) Apply(s *StartSpanOption) {
+	s.StartTime = o.t
+}
+
+// StartTime sets an explicit start time instead of time.Now(),
+// e.g. to backfill spans from logs or to start a span when a message was enqueued.
+func StartTime(t time.Time) *StartTimeOption {
+	return &StartTimeOption{t: t}
+}
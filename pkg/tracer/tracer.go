@@ -2,12 +2,13 @@ package tracer
 
 import (
 	"context"
-	"strconv"
-	"strings"
 	"time"
 	"tracer/pkg/config"
+	"tracer/pkg/metrics"
 	"tracer/pkg/model"
+	"tracer/pkg/processor"
 	"tracer/pkg/reporter"
+	"tracer/pkg/resource"
 	"tracer/pkg/sampler"
 	"tracer/pkg/span"
 	"tracer/pkg/utils"
@@ -20,13 +21,36 @@ type Tracer struct {
 	Process     *model.Process
 	Reporter    *reporter.Reporter
 	Sampler     sampler.Sampler
+	Metrics     *metrics.TracerMetrics
+	IDGenerator utils.IDGenerator
+
+	sampledCounter    metrics.Counter
+	notSampledCounter metrics.Counter
+
+	processors        []processor.SpanProcessor
+	reporterProcessor processor.SpanProcessor
+	propagators       []Propagator
+	pprofLabels       bool
+	// onFinish and onMutationAfterFinish are built once so that starting a span does not allocate closures.
+	onFinish              func(s *span.ToModel)
+	onMutationAfterFinish func()
+}
+
+// New returns a NoopTracer when tracing is disabled in the configuration, and a Tracer otherwise.
+func New(conf *config.Configuration, options ...TracerOption) (Interface, error) {
+	if conf != nil && conf.IsDisabled() {
+		return NoopTracer{}, nil
+	}
+
+	return NewTracer(conf, options...)
 }
 
-// NewTracer creates a new Tracer instance with the given configuration and optional tags.
+// NewTracer creates a new Tracer instance with the given configuration and options.
 // It initializes the reporter, sampler, and process information.
-func NewTracer(conf *config.Configuration, tags ...config.Tag) (*Tracer, error) {
+// Fields left empty in conf are taken from config.Default(); use config.Load to also read env and files.
+func NewTracer(conf *config.Configuration, options ...TracerOption) (*Tracer, error) {
 	tracer := new(Tracer)
-	err := tracer.init(conf, tags...)
+	err := tracer.init(conf, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -34,31 +58,93 @@ func NewTracer(conf *config.Configuration, tags ...config.Tag) (*Tracer, error)
 	return tracer, nil
 }
 
-// init initializes the Tracer with configuration and tags.
+// init initializes the Tracer with configuration and options.
 // It sets up the reporter, sampler, and process details.
-func (t *Tracer) init(conf *config.Configuration, tags ...config.Tag) error {
-	t.ServiceName = conf.ServiceName
-	r, err := reporter.NewReporter(conf, tags...)
+func (t *Tracer) init(userConf *config.Configuration, options ...TracerOption) error {
+	var opts tracerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
+	conf := config.Default()
+	conf.Merge(userConf)
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	propagators, err := NewPropagators(conf.Propagation)
 	if err != nil {
 		return err
 	}
-	t.Reporter = r
+	t.propagators = propagators
+	t.pprofLabels = conf.PprofLabelsEnabled()
+
+	// detected < configured < passed in code
+	tags := resource.Merge(resource.Detect(conf.Resource), conf.Tags, opts.tags)
+	t.ServiceName = conf.ServiceName
+	// the generator must be known before Process.ID is generated below
+	t.IDGenerator = opts.idGenerator
+	if t.IDGenerator == nil {
+		idGenerator, err := utils.NewIDGenerator(conf)
+		if err != nil {
+			return err
+		}
+		t.IDGenerator = idGenerator
+	}
+
+	// one metric set per tracer, shared with the reporter and its batch
+	t.Metrics = metrics.NewTracerMetrics(conf.Metrics)
 	t.Sampler = sampler.NewSampler(conf)
+	t.sampledCounter = t.Metrics.SamplerDecision(t.Sampler.GetType(), true)
+	t.notSampledCounter = t.Metrics.SamplerDecision(t.Sampler.GetType(), false)
+	// the reporter sends this Process with every batch, so it needs the ID the spans carry
 	t.Process = model.NewProcess(conf.ServiceName, t.Sampler.GetTags()...)
+	t.Process.ID = t.IDGenerator.NewSpanID()
 	t.Process.Tags = append(t.Process.Tags, tags...)
+	r, err := reporter.NewReporter(conf, t.Metrics, t.Process)
+	if err != nil {
+		return err
+	}
+	t.Reporter = r
+	t.reporterProcessor = processor.NewReporterProcessor(r)
+	t.onFinish = func(s *span.ToModel) {
+		t.Metrics.SpansFinished.Inc(1)
+		for _, p := range t.processors {
+			p.OnEnd(s)
+		}
+		t.reporterProcessor.OnEnd(s)
+	}
+	t.onMutationAfterFinish = func() {
+		t.Metrics.SpansMutatedAfterFinish.Inc(1)
+	}
 	t.Reporter.Start()
 
 	return nil
 }
 
+// RegisterProcessor appends span processors to the chain.
+// They run in registration order, before the built-in reporter processor which is always last.
+// It must be called before the tracer starts spans.
+func (t *Tracer) RegisterProcessor(processors ...processor.SpanProcessor) {
+	t.processors = append(t.processors, processors...)
+}
+
 // StartSpan creates and starts a new Span with the given operation name and options.
 // Options can be used to set tags, references (child of, follow from), and start time.
-func (t *Tracer) StartSpan(operation string, options ...Option) *span.Span {
-
-	startSpanOption := new(StartSpanOption)
+// Span processors get context.Background() in OnStart; use StartSpanFromContext to hand them a context.
+func (t *Tracer) StartSpan(operation string, options ...Option) span.Interface {
+	return t.startSpan(context.Background(), operation, options...)
+}
 
-	for _, option := range options {
-		option.Apply(startSpanOption)
+// startSpan creates the span and runs the OnStart hooks with ctx.
+func (t *Tracer) startSpan(ctx context.Context, operation string, options ...Option) *span.Span {
+	// 没有 option 时不分配，减少未采样 span 的开销
+	startSpanOption := emptyStartSpanOption
+	if len(options) != 0 {
+		startSpanOption = new(StartSpanOption)
+		for _, option := range options {
+			option.Apply(startSpanOption)
+		}
 	}
 
 	var traceID string
@@ -66,11 +152,11 @@ func (t *Tracer) StartSpan(operation string, options ...Option) *span.Span {
 	if startSpanOption.TracerID != "" {
 		traceID = startSpanOption.TracerID
 	} else {
-		traceID = utils.CreateID()
+		traceID = t.IDGenerator.NewTraceID()
 	}
 
-	baggage := make(map[string]string)
-
+	// baggage 在第一次 SetBaggageItem 时才分配
+	var baggage map[string]string
 	if len(startSpanOption.Baggage) != 0 {
 		baggage = startSpanOption.Baggage
 	}
@@ -82,84 +168,118 @@ func (t *Tracer) StartSpan(operation string, options ...Option) *span.Span {
 		}
 	}
 
-	return &span.Span{
-		Operation: operation,
-		Context: span.SpanContext{
-			TraceID:  traceID,
-			SpanID:   utils.CreateID(),
-			ParentID: parentID,
-			Sampled:  t.Sampler.IsSample(traceID, operation),
-			Baggage:  baggage,
-		},
-		StartTime: time.Now(),
-		ProcessID: utils.CreateID(),
-		OnFinish: func(s *span.ToModel) {
-			t.Reporter.Store(*s)
-		},
-		Tags:       startSpanOption.Tags,
+	sc := span.SpanContext{
+		TraceID:  traceID,
+		SpanID:   t.IDGenerator.NewSpanID(),
+		ParentID: parentID,
+		Baggage:  baggage,
+		Debug:    startSpanOption.Debug,
+		DebugID:  startSpanOption.DebugID,
+	}
+
+	// debug 请求（来自 tracer-debug-id 或上游的 debug 标记）无视采样率，sampling.priority 可以覆盖采样器
+	sc.Sampled = sc.Debug || t.Sampler.IsSample(traceID, operation)
+	span.ApplySamplingPriority(&sc, startSpanOption.Tags)
+
+	t.Metrics.SpansStarted.Inc(1)
+	if sc.Sampled {
+		t.sampledCounter.Inc(1)
+	} else {
+		t.notSampledCounter.Inc(1)
+	}
+
+	tags := startSpanOption.Tags
+	if sc.DebugID != "" {
+		tags = append(tags[:len(tags):len(tags)], config.Tag{Key: span.DebugIDTagKey, Value: sc.DebugID})
+	}
+
+	startTime := startSpanOption.StartTime
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+
+	s := &span.Span{
+		Operation:  operation,
+		Context:    sc,
+		StartTime:  startTime,
+		ProcessID:  t.Process.ID,
+		OnFinish:   t.onFinish,
+		Tags:       tags,
 		References: startSpanOption.References,
+
+		OnMutationAfterFinish: t.onMutationAfterFinish,
 	}
 
+	for _, p := range t.processors {
+		p.OnStart(ctx, s)
+	}
+	t.reporterProcessor.OnStart(ctx, s)
+
+	return s
 }
 
 // Inject 进程外 即跨服务用
+// The context is written in every configured propagation format.
 func (t *Tracer) Inject(sc span.SpanContext, carrier Carrier) error {
-	carrier.Set("tracer_id", sc.TraceID)
-	carrier.Set("span_id", sc.SpanID)
-	carrier.Set("sampled", strconv.FormatBool(sc.Sampled))
-
-	sc.ForeachBaggageItem(func(k, v string) {
-		carrier.Set("baggage_"+k, v)
-	})
+	for _, p := range t.propagators {
+		p.Inject(sc, carrier)
+	}
 
 	return nil
 }
 
 // Extract 进程外 即跨服务用
+// The configured formats are tried in order and the first one found wins.
+// A tracer-debug-id header turns the result into a sampled, debug-flagged context,
+// even when the carrier holds no trace at all.
 func (t *Tracer) Extract(carrier Carrier) (span.SpanContext, error) {
 	sc := span.NewSpanContext()
-
-	carrier.Foreach(func(key string, v interface{}) {
-		value := v.(string)
-
-		switch key {
-		case "tracer_id":
-			sc.TraceID = value
-		case "span_id":
-			sc.SpanID = value
-		case "sampled":
-			sc.Sampled, _ = strconv.ParseBool(value)
-		default:
-			if strings.HasPrefix(key, "baggage_") {
-				realKey := strings.TrimPrefix(key, "baggage_")
-				sc.Baggage[realKey] = value
-			}
+	for _, p := range t.propagators {
+		if extracted, ok := p.Extract(carrier); ok {
+			sc = extracted
+			break
 		}
+	}
 
-	})
-
-	//sc.TraceID = carrier.Get("tracer_id").(string)
-	//sc.ParentID = carrier.Get("span_id").(string)
-	//sc.Sampled = carrier.Get("sampled").(bool)
-	//
-	//sc.ForeachBaggageItem(func(k, v string) {
-	//	sc.Baggage[k] = carrier.Get("baggage_" + k).(string)
-	//})
+	if debugID := extractDebugID(carrier); debugID != "" {
+		sc.Debug = true
+		sc.DebugID = debugID
+	}
+	if sc.Debug {
+		sc.Sampled = true
+	}
 
 	return sc, nil
 }
 
-type SpanKeyType struct {
+// StartSpanFromContext starts a span whose parent is the span stored in ctx, if any,
+// and returns it together with a context that carries the new span.
+// An explicit ChildOf option still takes precedence over the span found in ctx.
+func (t *Tracer) StartSpanFromContext(ctx context.Context, operation string, options ...Option) (span.Interface, context.Context) {
+	s := t.startSpanFromContext(ctx, operation, options...)
+	if t.pprofLabels {
+		ctx = applyPprofLabels(ctx, s)
+	}
+
+	return s, ContextWithSpan(ctx, s)
 }
 
-var spanKey = SpanKeyType{}
+// startSpanFromContext is StartSpanFromContext without the pprof labels,
+// for spans that run on another goroutine than the caller's.
+func (t *Tracer) startSpanFromContext(ctx context.Context, operation string, options ...Option) *span.Span {
+	if parent := SpanFromContext(ctx); !parent.IsNoop() {
+		options = append([]Option{ChildOf(parent.SpanContext())}, options...)
+	}
+
+	return t.startSpan(ctx, operation, options...)
+}
 
 // SpanFromContext 进程内部使用（即服务内部）
-func (t *Tracer) SpanFromContext(ctx context.Context) *span.Span {
-	return ctx.Value(spanKey).(*span.Span)
+func (t *Tracer) SpanFromContext(ctx context.Context) span.Interface {
+	return SpanFromContext(ctx)
 }
 
 // ContextFromSpan 进程内部使用（即服务内部）
-func (t *Tracer) ContextFromSpan(ctx context.Context, span *span.Span) context.Context {
-	return context.WithValue(ctx, spanKey, span)
+func (t *Tracer) ContextFromSpan(ctx context.Context, span span.Interface) context.Context {
+	return ContextWithSpan(ctx, span)
 }
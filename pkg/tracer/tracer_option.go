@@ -0,0 +1,29 @@
+package tracer
+
+import (
+	"tracer/pkg/config"
+	"tracer/pkg/utils"
+)
+
+// TracerOption configures a Tracer in NewTracer, before the process ID is generated.
+type TracerOption func(o *tracerOptions)
+
+type tracerOptions struct {
+	idGenerator utils.IDGenerator
+	tags        []config.Tag
+}
+
+// WithIDGenerator makes the tracer create trace, span and process IDs with g
+// instead of the generator described by conf.IDGenerator.
+func WithIDGenerator(g utils.IDGenerator) TracerOption {
+	return func(o *tracerOptions) {
+		o.idGenerator = g
+	}
+}
+
+// WithProcessTags adds process tags. They win over detected and configured tags.
+func WithProcessTags(tags ...config.Tag) TracerOption {
+	return func(o *tracerOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
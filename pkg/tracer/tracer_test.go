@@ -0,0 +1,128 @@
+package tracer
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+	"tracer/internal/testutil"
+	"tracer/pkg/config"
+	"tracer/pkg/model"
+)
+
+// BenchmarkNoopTracer shows that a disabled tracer costs nothing per span.
+func BenchmarkNoopTracer(b *testing.B) {
+	t := NoopTracer{}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, _ := t.StartSpanFromContext(ctx, "op")
+		s.SetTag("k", "v")
+		s.Finish()
+	}
+}
+
+// BenchmarkUnsampledSpan measures a span dropped by the sampler: no tags or logs are recorded
+// and nothing is reported.
+func BenchmarkUnsampledSpan(b *testing.B) {
+	t := newTestTracer(b, withSamplerParam(0))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := t.StartSpan("op")
+		s.SetTag("k", "v")
+		s.Finish()
+	}
+}
+
+// fixedIDGenerator returns the same IDs every time.
+type fixedIDGenerator struct{}
+
+func (fixedIDGenerator) NewTraceID() string { return "trace-fixed" }
+func (fixedIDGenerator) NewSpanID() string  { return "span-fixed" }
+
+func TestWithIDGenerator(t *testing.T) {
+	tr := newTestTracer(t, withTracerOptions(WithIDGenerator(fixedIDGenerator{}), WithProcessTags(config.Tag{Key: "region", Value: "eu"})))
+
+	if tr.Process.ID != "span-fixed" {
+		t.Fatalf("Process.ID = %q, want it from the injected generator", tr.Process.ID)
+	}
+
+	s := tr.StartSpan("op")
+	defer s.Finish()
+	if sc := s.SpanContext(); sc.TraceID != "trace-fixed" || sc.SpanID != "span-fixed" {
+		t.Fatalf("span context = %s/%s, want the injected IDs", sc.TraceID, sc.SpanID)
+	}
+
+	if region, _ := testutil.TagValue(tr.Process.Tags, "region"); region != "eu" {
+		t.Fatalf("process tag region = %v, want eu", region)
+	}
+}
+
+func TestNoopTracer(t *testing.T) {
+	var tr Interface = NoopTracer{}
+	ctx := context.Background()
+
+	s, spanCtx := tr.StartSpanFromContext(ctx, "op")
+	if !s.IsNoop() {
+		t.Fatal("NoopTracer started a recording span")
+	}
+	if spanCtx != ctx {
+		t.Fatal("NoopTracer changed the context")
+	}
+
+	// a careless caller cannot leak state into the span every other caller gets
+	s.SetTag("k", "v")
+	s.SetBaggageItem("tenant", "acme")
+	s.Finish()
+	other := tr.StartSpan("other")
+	if other.GetBaggageItem("tenant") != "" || len(other.SpanContext().Baggage) != 0 {
+		t.Fatal("baggage set on one no-op span is seen on another")
+	}
+
+	real := newTestTracer(t).StartSpan("real")
+	defer real.Finish()
+	if got := tr.SpanFromContext(tr.ContextFromSpan(ctx, real)); got != real {
+		t.Fatal("ContextFromSpan/SpanFromContext did not round-trip the span")
+	}
+	if got := tr.SpanFromContext(ctx); !got.IsNoop() {
+		t.Fatal("SpanFromContext without a span did not return a no-op span")
+	}
+}
+
+func TestReporterSendsProcessID(t *testing.T) {
+	agent, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	tr := newTestTracer(t, withAgentAddr(agent.LocalAddr().String()))
+
+	tr.StartSpan("op").Finish()
+	if err := tr.Reporter.Send(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64<<10)
+	_ = agent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := agent.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkg model.Package
+	if err := json.Unmarshal(buf[:n], &pkg); err != nil {
+		t.Fatal(err)
+	}
+
+	if pkg.Process.ID == "" || pkg.Process.ID != tr.Process.ID {
+		t.Fatalf("batch process ID = %q, want the tracer's %q", pkg.Process.ID, tr.Process.ID)
+	}
+	if len(pkg.Spans) != 1 || pkg.Spans[0].ProcessID != pkg.Process.ID {
+		t.Fatalf("spans = %+v, want one span with process ID %q", pkg.Spans, pkg.Process.ID)
+	}
+}
@@ -20,3 +20,18 @@ func WithTag(key string, value interface{}) *WithTagOption {
 		value: value,
 	}
 }
+
+type WithTagsOption struct {
+	tags []config.Tag
+}
+
+func (o *WithTagsOption) Apply(s *StartSpanOption) {
+	s.Tags = append(s.Tags, o.tags...)
+}
+
+// WithTags adds several tags at once, e.g. WithTags(semconv.HTTPMethod("GET"), semconv.HTTPRoute("/user/:id")).
+func WithTags(tags ...config.Tag) *WithTagsOption {
+	return &WithTagsOption{
+		tags: tags,
+	}
+}
@@ -2,9 +2,9 @@ package transport
 
 import (
 	"encoding/json"
-	"fmt"
 	"sync"
 	"tracer/pkg/config"
+	"tracer/pkg/metrics"
 	"tracer/pkg/model"
 	"tracer/pkg/span"
 )
@@ -15,20 +15,28 @@ type Batch struct {
 	process  *model.Process
 	spans    []span.ToModel
 	fullChan chan struct{}
+	metrics  *metrics.TracerMetrics
 }
 
-func NewBatch(conf *config.Configuration, fullChan chan struct{}, tags ...config.Tag) *Batch {
+// NewBatch creates a Batch that records its self-telemetry in m, the metrics of its tracer.
+// A nil m discards the measurements. process is sent with every batch, so it should be the
+// tracer's Process: the agent matches the batch to its spans by the process ID.
+func NewBatch(conf *config.Configuration, fullChan chan struct{}, m *metrics.TracerMetrics, process *model.Process) *Batch {
 	batch := new(Batch)
-	batch.init(conf, fullChan, tags...)
+	batch.init(conf, fullChan, m, process)
 
 	return batch
 }
 
-func (b *Batch) init(conf *config.Configuration, fullCh chan struct{}, tags ...config.Tag) {
+func (b *Batch) init(conf *config.Configuration, fullCh chan struct{}, m *metrics.TracerMetrics, process *model.Process) {
 	b.maxQueue = conf.Reporter.QueueSize
-	b.process = model.NewProcess(conf.ServiceName, tags...)
+	b.process = process
 	b.spans = make([]span.ToModel, 0, b.maxQueue)
 	b.fullChan = fullCh
+	if m == nil {
+		m = metrics.NewTracerMetrics(nil)
+	}
+	b.metrics = m
 }
 
 func (b *Batch) Start() {}
@@ -36,44 +44,77 @@ func (b *Batch) Start() {}
 func (b *Batch) Flush() {
 
 	b.spans = b.spans[:0] // 不加锁是因为用这个函数的时候已经锁了
+	b.metrics.ReporterQueueLength.Update(0)
 }
 
+// Push adds span to the batch and wakes the Reporter once the batch is full.
+// While the batch stays full the span is dropped and counted in SpansDropped,
+// so a Reporter that falls behind cannot make the batch grow without bound.
 func (b *Batch) Push(span span.ToModel) {
 	b.mu.Lock()
+	if uint(len(b.spans)) >= b.maxQueue {
+		b.mu.Unlock()
+		b.metrics.SpansDropped.Inc(1)
+		b.signalFull()
+		return
+	}
 	b.spans = append(b.spans, span)
-	isFull := len(b.spans) >= cap(b.spans)
+	length := len(b.spans)
+	isFull := uint(length) >= b.maxQueue
 	b.mu.Unlock()
 
+	b.metrics.ReporterQueueLength.Update(int64(length))
+
 	if isFull {
-		select {
-		case b.fullChan <- struct{}{}: // 成功发送信号，Reporter 将开始工作
-		default:
-			// 信号发送失败（Reporter 还没处理完上一次信号）
-			// 我们什么都不做。因为：
-			// 1. 数据已在 b.spans 里，下次 Reporter 动作时会一并带走
-			// 2. 避免了因为 Reporter 慢而卡死业务主逻辑
-		}
+		b.signalFull()
+	}
+}
+
+func (b *Batch) signalFull() {
+	select {
+	case b.fullChan <- struct{}{}: // 成功发送信号，Reporter 将开始工作
+	default:
+		// 信号发送失败（Reporter 还没处理完上一次信号）
+		// 我们什么都不做。因为：
+		// 1. 数据已在 b.spans 里，下次 Reporter 动作时会一并带走
+		// 2. 避免了因为 Reporter 慢而卡死业务主逻辑
 	}
 }
 
-func (b *Batch) GetData() ([]byte, error) {
+// GetData encodes the batch, empties it and returns the number of spans it held.
+// An empty batch gives nil data and 0.
+func (b *Batch) GetData() ([]byte, int, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	size := len(b.spans)
+	if size == 0 {
+		return nil, 0, nil
+	}
+
 	p := model.Package{
 		Process: *b.process,
 		Spans:   b.spans,
 	}
 
-	fmt.Println(p)
-
 	data, err := json.Marshal(&p)
+	if err != nil {
+		b.metrics.SpansDropped.Inc(int64(size))
+	}
 
 	b.Flush()
 
-	return data, err
+	return data, size, err
 }
 
 func (b *Batch) IsEmpty() bool {
-	return len(b.spans) == 0
+	return b.Len() == 0
+}
+
+// Len returns the number of spans waiting to be sent.
+func (b *Batch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.spans)
 }
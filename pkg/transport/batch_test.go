@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"tracer/pkg/config"
+	"tracer/pkg/metrics"
+	"tracer/pkg/model"
+	"tracer/pkg/span"
+)
+
+func newTestBatch(t *testing.T, queueSize uint) (*Batch, *metrics.PrometheusFactory, chan struct{}) {
+	t.Helper()
+	conf := config.Default()
+	conf.Reporter.QueueSize = queueSize
+	f := metrics.NewPrometheusFactory()
+	fullCh := make(chan struct{}, 1)
+
+	return NewBatch(conf, fullCh, metrics.NewTracerMetrics(f), &model.Process{ServiceName: "test", ID: "p1"}), f, fullCh
+}
+
+// metricLine returns the line of the Prometheus output that starts with name and a space.
+func metricLine(t *testing.T, f *metrics.PrometheusFactory, name string) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := f.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, name+" ") {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestBatchPushDropsWhenFull(t *testing.T) {
+	b, f, fullCh := newTestBatch(t, 2)
+
+	b.Push(span.ToModel{Operation: "a"})
+	if len(fullCh) != 0 {
+		t.Fatal("signalled full with 1 of 2 spans")
+	}
+	b.Push(span.ToModel{Operation: "b"})
+	if len(fullCh) != 1 {
+		t.Fatal("did not signal full with 2 of 2 spans")
+	}
+	b.Push(span.ToModel{Operation: "c"})
+
+	if got := metricLine(t, f, "tracer_spans_dropped_total"); got != "tracer_spans_dropped_total 1" {
+		t.Fatalf("dropped line = %q, want the span pushed into the full batch", got)
+	}
+
+	data, size, err := b.GetData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkg model.Package
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		t.Fatal(err)
+	}
+	if size != 2 || len(pkg.Spans) != 2 || pkg.Spans[1].Operation != "b" {
+		t.Fatalf("GetData = %d spans %+v, want a and b", size, pkg.Spans)
+	}
+	if pkg.Process.ID != "p1" {
+		t.Fatalf("process ID = %q, want p1", pkg.Process.ID)
+	}
+
+	// 发送之后又有空间了
+	b.Push(span.ToModel{Operation: "d"})
+	if b.Len() != 1 {
+		t.Fatalf("Len = %d after GetData and one Push, want 1", b.Len())
+	}
+}
+
+func TestBatchGetDataEmpty(t *testing.T) {
+	b, _, _ := newTestBatch(t, 2)
+
+	data, size, err := b.GetData()
+	if data != nil || size != 0 || err != nil {
+		t.Fatalf("GetData = %q, %d, %v on an empty batch, want nil, 0, nil", data, size, err)
+	}
+}
@@ -1,12 +1,9 @@
 package utils
 
-import "github.com/bwmarrin/snowflake"
+var defaultIDGenerator = NewRandomIDGenerator()
 
+// CreateID returns a random 64-bit ID in lowercase hex.
+// Tracers use their own IDGenerator; this is kept for callers that only need a unique ID.
 func CreateID() string {
-	node, err := snowflake.NewNode(1)
-	if err != nil {
-		panic(err)
-	}
-
-	return node.Generate().String()
+	return defaultIDGenerator.NewSpanID()
 }
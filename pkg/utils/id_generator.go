@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/bwmarrin/snowflake"
+	"math/rand/v2"
+	"tracer/pkg/config"
+)
+
+const (
+	IDGeneratorTypeRandom    = "random"
+	IDGeneratorTypeSnowflake = "snowflake"
+)
+
+// IDGenerator creates the IDs of traces and spans.
+// Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NewTraceID() string
+	NewSpanID() string
+}
+
+// NewIDGenerator creates the IDGenerator described by the configuration.
+// It returns a RandomIDGenerator when nothing is configured.
+func NewIDGenerator(conf *config.Configuration) (IDGenerator, error) {
+	if conf.IDGenerator == nil {
+		return NewRandomIDGenerator(), nil
+	}
+
+	switch conf.IDGenerator.Type {
+	case "", IDGeneratorTypeRandom:
+		return NewRandomIDGenerator(), nil
+	case IDGeneratorTypeSnowflake:
+		return NewSnowflakeIDGenerator(conf.IDGenerator.NodeID)
+	}
+
+	return nil, fmt.Errorf("unknown id generator type %q", conf.IDGenerator.Type)
+}
+
+// RandomIDGenerator creates 128-bit trace IDs and 64-bit span IDs in lowercase hex.
+// It uses math/rand/v2, whose global source is per-thread and needs no locking.
+type RandomIDGenerator struct{}
+
+// NewRandomIDGenerator creates a new RandomIDGenerator.
+func NewRandomIDGenerator() *RandomIDGenerator {
+	return &RandomIDGenerator{}
+}
+
+// NewTraceID returns 32 hex characters. The all-zero ID is never returned.
+func (g *RandomIDGenerator) NewTraceID() string {
+	var buf [16]byte
+	var dst [32]byte
+	hi, lo := rand.Uint64(), nonZeroUint64()
+	binary.BigEndian.PutUint64(buf[:8], hi)
+	binary.BigEndian.PutUint64(buf[8:], lo)
+	hex.Encode(dst[:], buf[:])
+
+	return string(dst[:])
+}
+
+// NewSpanID returns 16 hex characters. The all-zero ID is never returned.
+func (g *RandomIDGenerator) NewSpanID() string {
+	var buf [8]byte
+	var dst [16]byte
+	binary.BigEndian.PutUint64(buf[:], nonZeroUint64())
+	hex.Encode(dst[:], buf[:])
+
+	return string(dst[:])
+}
+
+func nonZeroUint64() uint64 {
+	for {
+		if v := rand.Uint64(); v != 0 {
+			return v
+		}
+	}
+}
+
+// SnowflakeIDGenerator creates time-ordered decimal IDs.
+// Every process must use its own node ID, otherwise IDs can collide.
+type SnowflakeIDGenerator struct {
+	node *snowflake.Node
+}
+
+// NewSnowflakeIDGenerator creates a new SnowflakeIDGenerator for the given node (0-1023).
+func NewSnowflakeIDGenerator(nodeID int64) (*SnowflakeIDGenerator, error) {
+	node, err := snowflake.NewNode(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnowflakeIDGenerator{node: node}, nil
+}
+
+func (g *SnowflakeIDGenerator) NewTraceID() string {
+	return g.node.Generate().String()
+}
+
+func (g *SnowflakeIDGenerator) NewSpanID() string {
+	return g.node.Generate().String()
+}
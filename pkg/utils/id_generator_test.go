@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"testing"
+	"tracer/pkg/config"
+)
+
+func TestRandomIDGeneratorFormat(t *testing.T) {
+	g := NewRandomIDGenerator()
+
+	for i := 0; i < 1000; i++ {
+		traceID := g.NewTraceID()
+		if len(traceID) != 32 {
+			t.Fatalf("trace ID %q has %d characters, want 32 (128 bits)", traceID, len(traceID))
+		}
+		if _, err := hex.DecodeString(traceID); err != nil {
+			t.Fatalf("trace ID %q is not hex: %v", traceID, err)
+		}
+		if traceID == "00000000000000000000000000000000" {
+			t.Fatal("trace ID is all zeros")
+		}
+
+		spanID := g.NewSpanID()
+		if len(spanID) != 16 {
+			t.Fatalf("span ID %q has %d characters, want 16 (64 bits)", spanID, len(spanID))
+		}
+		if _, err := hex.DecodeString(spanID); err != nil {
+			t.Fatalf("span ID %q is not hex: %v", spanID, err)
+		}
+	}
+}
+
+func TestSnowflakeIDGeneratorFormat(t *testing.T) {
+	g, err := NewSnowflakeIDGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := int64(0)
+	for i := 0; i < 1000; i++ {
+		id, err := strconv.ParseInt(g.NewSpanID(), 10, 64)
+		if err != nil {
+			t.Fatalf("snowflake ID is not decimal: %v", err)
+		}
+		if id <= prev {
+			t.Fatalf("snowflake ID %d is not after %d", id, prev)
+		}
+		prev = id
+	}
+
+	if _, err := NewSnowflakeIDGenerator(1024); err == nil {
+		t.Fatal("node 1024 accepted, want an error")
+	}
+}
+
+func TestIDGeneratorsUniqueUnderConcurrency(t *testing.T) {
+	snowflake, err := NewSnowflakeIDGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		g    IDGenerator
+	}{
+		{IDGeneratorTypeRandom, NewRandomIDGenerator()},
+		{IDGeneratorTypeSnowflake, snowflake},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const goroutines, perGoroutine = 8, 2000
+
+			var mu sync.Mutex
+			seen := make(map[string]struct{}, goroutines*perGoroutine*2)
+			var wg sync.WaitGroup
+			for i := 0; i < goroutines; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ids := make([]string, 0, perGoroutine*2)
+					for j := 0; j < perGoroutine; j++ {
+						ids = append(ids, tt.g.NewTraceID(), tt.g.NewSpanID())
+					}
+
+					mu.Lock()
+					defer mu.Unlock()
+					for _, id := range ids {
+						seen[id] = struct{}{}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if len(seen) != goroutines*perGoroutine*2 {
+				t.Fatalf("%d unique IDs, want %d", len(seen), goroutines*perGoroutine*2)
+			}
+		})
+	}
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *config.IDGeneratorConfig
+		wantErr bool
+	}{
+		{"nothing configured", nil, false},
+		{"random", &config.IDGeneratorConfig{Type: IDGeneratorTypeRandom}, false},
+		{"snowflake", &config.IDGeneratorConfig{Type: IDGeneratorTypeSnowflake, NodeID: 3}, false},
+		{"unknown", &config.IDGeneratorConfig{Type: "uuid"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewIDGenerator(&config.Configuration{IDGenerator: tt.conf})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewIDGenerator error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && g == nil {
+				t.Fatal("NewIDGenerator returned a nil generator")
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	pb "tracer/internal/proto"
 	"tracer/pkg/model"
+	"tracer/pkg/semconv"
 )
 
 func toString(v interface{}) string {
@@ -63,6 +64,29 @@ func flattenLogs(
 	return
 }
 
+func flattenReferences(
+	refs []*pb.Reference,
+) (traceIDs []string, spanIDs []string, types []string, attrs []map[string]string) {
+
+	for _, ref := range refs {
+		if ref == nil {
+			continue
+		}
+
+		traceIDs = append(traceIDs, ref.TraceId)
+		spanIDs = append(spanIDs, ref.SpanId)
+		types = append(types, ref.RefType)
+
+		if len(ref.Attributes) > 0 {
+			attrs = append(attrs, ref.Attributes)
+		} else {
+			attrs = append(attrs, nil)
+		}
+	}
+
+	return
+}
+
 func FlatSpanToClickHouseSpan(fs *model.FlatSpan) *model.StorageSpan {
 	if fs == nil {
 		return nil
@@ -80,6 +104,8 @@ func FlatSpanToClickHouseSpan(fs *model.FlatSpan) *model.StorageSpan {
 
 	// Logs → Events
 	eventNames, eventTimes, eventAttrs := flattenLogs(fs.Logs)
+	// References → Links
+	linkTraceIDs, linkSpanIDs, linkTypes, linkAttrs := flattenReferences(fs.References)
 	fmt.Println("parent_id", fs.ParentSpanID)
 
 	return &model.StorageSpan{
@@ -95,7 +121,7 @@ func FlatSpanToClickHouseSpan(fs *model.FlatSpan) *model.StorageSpan {
 		DurationUs:  fs.Duration,
 
 		StatusCode:    inferStatus(fs.Tags),
-		StatusMessage: "",
+		StatusMessage: inferStatusMessage(fs.Tags),
 
 		Attributes: attrs,
 
@@ -103,25 +129,40 @@ func FlatSpanToClickHouseSpan(fs *model.FlatSpan) *model.StorageSpan {
 		EventTimesUs: eventTimes,
 		EventAttrs:   eventAttrs,
 
+		LinkTraceIDs: linkTraceIDs,
+		LinkSpanIDs:  linkSpanIDs,
+		LinkTypes:    linkTypes,
+		LinkAttrs:    linkAttrs,
+
 		ProcessID:     fs.ProcessID,
 		ResourceAttrs: mapToStringMap(fs.ProcessTags),
 
 		TimestampUs: fs.StartTime,
+
+		Debug: fs.Debug,
 	}
 }
 
 func inferSpanKind(tags map[string]interface{}) string {
-	if v, ok := tags["span.kind"]; ok {
-		return strings.ToUpper(toString(v))
+	if v, ok := tags[semconv.SpanKindKey]; ok {
+		return strings.ToUpper(semconv.CanonicalSpanKind(toString(v)))
 	}
 	return "INTERNAL"
 }
 
 func inferStatus(tags map[string]interface{}) string {
-	if v, ok := tags["error"]; ok {
+	if v, ok := tags[semconv.ErrorKey]; ok {
 		if toString(v) == "true" || toString(v) == "1" {
 			return "ERROR"
 		}
 	}
 	return "OK"
 }
+
+// inferStatusMessage returns the status description OpenTelemetry spans carry, if any.
+func inferStatusMessage(tags map[string]interface{}) string {
+	if v, ok := tags[semconv.StatusDescriptionKey]; ok {
+		return toString(v)
+	}
+	return ""
+}
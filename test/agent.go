@@ -1,7 +1,14 @@
 package main
 
-import "tracer/cmd"
+import (
+	"flag"
+	"log"
+	"os"
+	"tracer/cmd"
+)
 
 func main() {
-	cmd.NewAgent()
+	if err := cmd.NewAgent(os.Args[1:]); err != nil && err != flag.ErrHelp {
+		log.Fatal(err)
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"time"
 	"tracer/pkg/config"
+	"tracer/pkg/semconv"
 	"tracer/pkg/tracer"
 )
 
@@ -20,10 +21,10 @@ func main() {
 			Duration:  time.Second,
 			AgentAddr: "127.0.0.1:8888",
 		},
-	}, config.Tag{
+	}, tracer.WithProcessTags(config.Tag{
 		Key:   "ip",
 		Value: "localhost",
-	})
+	}))
 
 	if err != nil {
 		panic(err)
@@ -32,12 +33,13 @@ func main() {
 	time.Sleep(time.Second)
 
 	span := t.StartSpan("HTTP GET /user/:id",
-		tracer.WithTag("http.method", "GET"),
-		tracer.WithTag("http.path", "/user/123"))
+		tracer.WithTags(
+			semconv.HTTPMethod("GET"),
+			semconv.HTTPTarget("/user/123")))
 
 	time.Sleep(time.Second)
 
-	span.SetTag("http.status_code", 200)
+	span.SetTags(semconv.HTTPStatusCode(200))
 	span.SetBaggageItem("uid", "123456")
 	span.LogFields(
 		config.Tag{
@@ -53,7 +55,7 @@ func main() {
 		MD: make(metadata.MD),
 	}
 
-	if err := t.Inject(span.Context, &carrier); err != nil {
+	if err := t.Inject(span.SpanContext(), &carrier); err != nil {
 		panic(err)
 	}
 
@@ -68,7 +70,7 @@ func main() {
 	childSpan := t.StartSpan("order_service", tracer.ChildOf(spanCtx))
 	//fmt.Println(childSpan)
 	time.Sleep(time.Second)
-	fmt.Println(childSpan.Context.ParentID)
+	fmt.Println(childSpan.SpanContext().ParentID)
 
 	childSpan.Finish()
 